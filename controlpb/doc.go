@@ -0,0 +1,5 @@
+// Package controlpb is the gRPC control API of the ntpcl daemon, generated
+// from control.proto, with the client fleet-management agents use.
+package controlpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
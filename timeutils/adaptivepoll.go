@@ -0,0 +1,137 @@
+package timeutils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Adaptive polling follows the idea of ntpd's poll adjustment: the interval
+// doubles after a run of measurements that agree within their jitter and
+// halves when one does not.
+const (
+	// DefaultPollMin and DefaultPollMax are ntpd's minpoll 6 and maxpoll 10.
+	DefaultPollMin = 64 * time.Second
+	DefaultPollMax = 1024 * time.Second
+
+	pollGate    = 4  // offsets within pollGate times the jitter count as stable
+	pollStable  = 4  // stable measurements in a row before the interval doubles
+	pollWindow  = 8  // measurements the jitter is computed over
+	pollOutlier = 16 // offsets beyond this many times the jitter reset to the minimum
+)
+
+// ParsePollInterval parses a poll interval as a Go duration ("64s") or, as
+// in ntpd's minpoll and maxpoll, a power of two in seconds ("6" is 64s).
+func ParsePollInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if exponent, err := strconv.Atoi(s); err == nil {
+		if exponent < 0 || exponent > 17 {
+			return 0, fmt.Errorf("poll exponent %d is outside 0-17", exponent)
+		}
+		return time.Duration(1<<exponent) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid poll interval %q: use a duration or a power of two", s)
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("poll interval %v is below 1s", d)
+	}
+	return d, nil
+}
+
+// AdaptivePoll adjusts the interval of daemon mode between Min and Max from
+// the measured offsets: it polls faster while the offset is large or jumps
+// around, and backs off while the clock is stable.
+type AdaptivePoll struct {
+	mu       sync.Mutex
+	min, max time.Duration
+	interval time.Duration
+	offsets  []time.Duration // since the last step
+	// residuals are the differences from the expected offsets, whose spread
+	// is the jitter.
+	residuals []time.Duration
+	stable    int
+}
+
+// NewAdaptivePoll starts at the minimum interval, as the clock is unknown.
+func NewAdaptivePoll(minInterval, maxInterval time.Duration) *AdaptivePoll {
+	return &AdaptivePoll{min: minInterval, max: maxInterval, interval: minInterval}
+}
+
+// SetBounds changes the bounds, after a config reload.
+func (p *AdaptivePoll) SetBounds(minInterval, maxInterval time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.min, p.max = minInterval, maxInterval
+	p.interval = max(p.min, min(p.max, p.interval))
+}
+
+// Interval returns the current poll interval.
+func (p *AdaptivePoll) Interval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+// Observe takes a successful measurement into account and returns the next
+// interval. Each offset is judged against the offsets since the clock was
+// last stepped (zero right after a step), so a clock that is only monitored
+// and has a steady offset backs off too.
+func (p *AdaptivePoll) Observe(offset, uncertainty time.Duration, stepped bool) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.offsets) > 0 {
+		expected, _ := offsetStats(p.offsets)
+		_, jitter := offsetStats(p.residuals)
+		jitter = max(jitter, uncertainty)
+		residual := (offset - expected).Abs()
+		switch {
+		case residual > pollOutlier*jitter:
+			p.stable, p.interval = 0, p.min
+		case residual > pollGate*jitter:
+			p.stable, p.interval = 0, max(p.min, p.interval/2)
+		default:
+			p.stable++
+			if p.stable >= pollStable {
+				p.stable, p.interval = 0, min(p.max, p.interval*2)
+			}
+		}
+		p.residuals = appendWindow(p.residuals, offset-expected)
+	}
+	if stepped {
+		p.offsets = []time.Duration{0}
+	} else {
+		p.offsets = appendWindow(p.offsets, offset)
+	}
+	return p.interval
+}
+
+func appendWindow(values []time.Duration, v time.Duration) []time.Duration {
+	values = append(values, v)
+	if len(values) > pollWindow {
+		values = values[1:]
+	}
+	return values
+}
+
+// offsetStats returns the mean of the offsets and their standard deviation.
+func offsetStats(offsets []time.Duration) (time.Duration, time.Duration) {
+	if len(offsets) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, o := range offsets {
+		sum += float64(o)
+	}
+	mean := sum / float64(len(offsets))
+	var squares float64
+	for _, o := range offsets {
+		squares += (float64(o) - mean) * (float64(o) - mean)
+	}
+	return time.Duration(mean), time.Duration(math.Sqrt(squares / float64(len(offsets))))
+}
@@ -0,0 +1,98 @@
+package timeutils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Agreement is how many of the servers must agree on the time before it is
+// used, as in --require-agreement 2/3.
+type Agreement struct {
+	Quorum  int // servers that must agree
+	Servers int // servers queried
+	// Tolerance is the largest difference between the offsets of servers that agree.
+	Tolerance time.Duration
+}
+
+// ServerOffset is the offset one server measured, or why it could not be measured.
+type ServerOffset struct {
+	Server string
+	Offset time.Duration
+	Err    error
+}
+
+// ParseAgreement parses a quorum given as "K/N": K of the N servers must agree.
+func ParseAgreement(s string) (Agreement, error) {
+	quorum, servers, ok := strings.Cut(s, "/")
+	k, err1 := strconv.Atoi(strings.TrimSpace(quorum))
+	n, err2 := strconv.Atoi(strings.TrimSpace(servers))
+	if !ok || err1 != nil || err2 != nil {
+		return Agreement{}, fmt.Errorf("%q is not K/N, e.g. 2/3", s)
+	}
+	if k < 1 || k > n {
+		return Agreement{}, fmt.Errorf("%q needs 1 <= K <= N", s)
+	}
+	return Agreement{Quorum: k, Servers: n}, nil
+}
+
+// String returns the quorum as K/N.
+func (a Agreement) String() string {
+	return fmt.Sprintf("%d/%d", a.Quorum, a.Servers)
+}
+
+// Agreeing returns the indexes of the largest group of offsets that lie within
+// Tolerance of each other, in the order of offsets. Failed measurements are in
+// no group; of groups of the same size, the one with the narrowest spread wins.
+func (a Agreement) Agreeing(offsets []ServerOffset) []int {
+	var measured []int
+	for i, o := range offsets {
+		if o.Err == nil {
+			measured = append(measured, i)
+		}
+	}
+	sort.SliceStable(measured, func(i, j int) bool { return offsets[measured[i]].Offset < offsets[measured[j]].Offset })
+
+	var best []int
+	var bestSpread time.Duration
+	for start := range measured {
+		end := start
+		for end+1 < len(measured) && offsets[measured[end+1]].Offset-offsets[measured[start]].Offset <= a.Tolerance {
+			end++
+		}
+		spread := offsets[measured[end]].Offset - offsets[measured[start]].Offset
+		if size := end - start + 1; size > len(best) || (size == len(best) && spread < bestSpread) {
+			best, bestSpread = measured[start:end+1], spread
+		}
+	}
+	group := append([]int(nil), best...)
+	sort.Ints(group)
+	return group
+}
+
+// DisagreementError reports that fewer servers than the quorum agreed.
+type DisagreementError struct {
+	Agreement Agreement
+	Offsets   []ServerOffset
+	Agreeing  int // size of the largest group that agreed
+}
+
+func (e *DisagreementError) Error() string {
+	return fmt.Sprintf("servers disagree: only %d of %d agree within %v, %s required: %s",
+		e.Agreeing, len(e.Offsets), e.Agreement.Tolerance, e.Agreement, FormatOffsets(e.Offsets))
+}
+
+// FormatOffsets lists the offset of each server, or why it failed, on one line.
+func FormatOffsets(offsets []ServerOffset) string {
+	var servers []string
+	for _, o := range offsets {
+		if o.Err != nil {
+			servers = append(servers, fmt.Sprintf("%s failed (%v)", o.Server, o.Err))
+		} else {
+			servers = append(servers, fmt.Sprintf("%s %v", o.Server, o.Offset))
+		}
+	}
+	return strings.Join(servers, ", ")
+}
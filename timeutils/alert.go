@@ -0,0 +1,158 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Alert kinds.
+const (
+	AlertOffset      = "offset_threshold"
+	AlertSyncFailure = "sync_failure"
+)
+
+// Alert describes a clock health problem sent to the configured sinks.
+type Alert struct {
+	Kind      string    `json:"kind"`
+	Severity  string    `json:"severity"`
+	Message   string    `json:"message"`
+	Hostname  string    `json:"hostname"`
+	Server    string    `json:"server,omitempty"`
+	Offset    float64   `json:"offset_seconds,omitempty"`
+	Threshold float64   `json:"threshold_seconds,omitempty"`
+	Failures  int       `json:"consecutive_failures,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// AlertSink delivers alerts to an external system.
+type AlertSink interface {
+	Send(alert Alert) error
+}
+
+// WebhookSink POSTs alerts as JSON to a URL, either as the raw alert or
+// formatted for a Slack, Discord or Microsoft Teams incoming webhook.
+type WebhookSink struct {
+	URL    string
+	Format string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink that POSTs alerts to url in the given format.
+func NewWebhookSink(url, format string) (*WebhookSink, error) {
+	if _, err := formatWebhookPayload(format, Alert{}); err != nil {
+		return nil, err
+	}
+	return &WebhookSink{URL: url, Format: format, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+// Send implements AlertSink.
+func (s *WebhookSink) Send(alert Alert) error {
+	payload, err := formatWebhookPayload(s.Format, alert)
+	if err != nil {
+		return err
+	}
+	return postJSON(s.client, s.URL, payload)
+}
+
+func postJSON(client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Alerter watches measurements and sends an alert when the offset crosses the
+// threshold or when queries fail a number of times in a row. Each condition
+// alerts once when it starts and re-arms when it clears.
+type Alerter struct {
+	Threshold   time.Duration
+	MaxFailures int
+	Sinks       []AlertSink
+
+	mu             sync.Mutex
+	hostname       string
+	offsetBreached bool
+	failures       int
+}
+
+// NewAlerter creates an alerter for the given offset threshold and consecutive failure count.
+func NewAlerter(threshold time.Duration, maxFailures int, sinks ...AlertSink) *Alerter {
+	hostname, _ := os.Hostname()
+	return &Alerter{Threshold: threshold, MaxFailures: maxFailures, Sinks: sinks, hostname: hostname}
+}
+
+// ObserveReport checks a successful measurement against the offset threshold.
+func (a *Alerter) ObserveReport(report Report) error {
+	a.mu.Lock()
+	a.failures = 0
+	offset := report.TimeDifference()
+	breached := a.Threshold > 0 && offset.Abs() > a.Threshold
+	crossed := breached && !a.offsetBreached
+	a.offsetBreached = breached
+	a.mu.Unlock()
+
+	if !crossed {
+		return nil
+	}
+	return a.send(Alert{
+		Kind:      AlertOffset,
+		Severity:  "warning",
+		Message:   fmt.Sprintf("clock offset %v against %s exceeds threshold %v", offset, report.Server, a.Threshold),
+		Server:    report.Server,
+		Offset:    offset.Seconds(),
+		Threshold: a.Threshold.Seconds(),
+	})
+}
+
+// ObserveFailure counts a failed query and alerts when MaxFailures is reached.
+func (a *Alerter) ObserveFailure(server string, err error) error {
+	a.mu.Lock()
+	a.failures++
+	failures := a.failures
+	a.mu.Unlock()
+
+	if a.MaxFailures <= 0 || failures != a.MaxFailures {
+		return nil
+	}
+	return a.send(Alert{
+		Kind:     AlertSyncFailure,
+		Severity: "critical",
+		Message:  fmt.Sprintf("time sync against %s failed %d times in a row", server, failures),
+		Server:   server,
+		Failures: failures,
+		Error:    err.Error(),
+	})
+}
+
+func (a *Alerter) send(alert Alert) error {
+	alert.Hostname = a.hostname
+	alert.Time = time.Now().UTC()
+
+	var errs []error
+	for _, sink := range a.Sinks {
+		if err := sink.Send(alert); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send alert: %v", errs)
+	}
+	return nil
+}
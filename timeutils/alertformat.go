@@ -0,0 +1,118 @@
+package timeutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Webhook payload formats understood by WebhookSink.
+const (
+	WebhookFormatJSON    = "json"
+	WebhookFormatSlack   = "slack"
+	WebhookFormatDiscord = "discord"
+	WebhookFormatTeams   = "teams"
+)
+
+// alertField is a labelled value shown in chat notifications.
+type alertField struct {
+	name, value string
+}
+
+// alertFields returns the fields shown for an alert in chat notifications.
+func alertFields(alert Alert) []alertField {
+	fields := []alertField{
+		{"Host", alert.Hostname},
+		{"Server", alert.Server},
+		{"Severity", alert.Severity},
+	}
+	if alert.Kind == AlertOffset {
+		fields = append(fields,
+			alertField{"Offset", time.Duration(alert.Offset * float64(time.Second)).String()},
+			alertField{"Threshold", time.Duration(alert.Threshold * float64(time.Second)).String()})
+	}
+	if alert.Failures > 0 {
+		fields = append(fields,
+			alertField{"Failures", fmt.Sprintf("%d", alert.Failures)},
+			alertField{"Error", alert.Error})
+	}
+	return fields
+}
+
+// alertColor returns the RGB color used for the alert severity.
+func alertColor(alert Alert) int {
+	if alert.Severity == "critical" {
+		return 0xD32F2F
+	}
+	return 0xF9A825
+}
+
+func alertTitle(alert Alert) string {
+	return fmt.Sprintf("ntpcl %s on %s", strings.ToUpper(alert.Severity), alert.Hostname)
+}
+
+// formatWebhookPayload renders an alert for the given webhook format.
+func formatWebhookPayload(format string, alert Alert) (any, error) {
+	switch format {
+	case "", WebhookFormatJSON:
+		return alert, nil
+	case WebhookFormatSlack:
+		return slackPayload(alert), nil
+	case WebhookFormatDiscord:
+		return discordPayload(alert), nil
+	case WebhookFormatTeams:
+		return teamsPayload(alert), nil
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+}
+
+func slackPayload(alert Alert) map[string]any {
+	var fields []map[string]any
+	for _, f := range alertFields(alert) {
+		fields = append(fields, map[string]any{"title": f.name, "value": f.value, "short": len(f.value) < 40})
+	}
+	return map[string]any{
+		"text": alertTitle(alert),
+		"attachments": []any{map[string]any{
+			"color":  fmt.Sprintf("#%06X", alertColor(alert)),
+			"text":   alert.Message,
+			"fields": fields,
+			"ts":     alert.Time.Unix(),
+		}},
+	}
+}
+
+func discordPayload(alert Alert) map[string]any {
+	var fields []map[string]any
+	for _, f := range alertFields(alert) {
+		fields = append(fields, map[string]any{"name": f.name, "value": f.value, "inline": len(f.value) < 40})
+	}
+	return map[string]any{
+		"embeds": []any{map[string]any{
+			"title":       alertTitle(alert),
+			"description": alert.Message,
+			"color":       alertColor(alert),
+			"fields":      fields,
+			"timestamp":   alert.Time.Format(time.RFC3339),
+		}},
+	}
+}
+
+func teamsPayload(alert Alert) map[string]any {
+	var facts []map[string]any
+	for _, f := range alertFields(alert) {
+		facts = append(facts, map[string]any{"name": f.name, "value": f.value})
+	}
+	return map[string]any{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"themeColor": fmt.Sprintf("%06X", alertColor(alert)),
+		"summary":    alert.Message,
+		"title":      alertTitle(alert),
+		"sections": []any{map[string]any{
+			"text":  alert.Message,
+			"facts": facts,
+		}},
+	}
+}
@@ -0,0 +1,14 @@
+//go:build linux && !android
+// +build linux,!android
+
+package timeutils
+
+// androidDefaults is only used on Android.
+func androidDefaults() PlatformDefaults {
+	return PlatformDefaults{}
+}
+
+// checkSu is only needed on Android, where the command method runs date through su.
+func checkSu() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// AuditTag is the syslog tag used for clock modification records.
+const AuditTag = "ntpcl-audit"
+
+// AuditEntry records a single change of the system clock.
+type AuditEntry struct {
+	Time    time.Time     `json:"time"`
+	OldTime time.Time     `json:"old_time"`
+	NewTime time.Time     `json:"new_time"`
+	Delta   time.Duration `json:"delta_ns"`
+	Server  string        `json:"server"`
+	Method  string        `json:"method"`
+	User    string        `json:"user"`
+}
+
+// NewAuditEntry builds an audit entry for a clock step from oldTime to newTime.
+func NewAuditEntry(oldTime, newTime time.Time, server, method string) AuditEntry {
+	return AuditEntry{
+		Time:    time.Now().UTC(),
+		OldTime: oldTime.UTC(),
+		NewTime: newTime.UTC(),
+		Delta:   newTime.Sub(oldTime),
+		Server:  server,
+		Method:  method,
+		User:    currentUser(),
+	}
+}
+
+// String renders the entry as a single human-readable line, as used for syslog.
+func (e AuditEntry) String() string {
+	return fmt.Sprintf("clock stepped by %v from %s to %s (server %s, method %s, user %s)",
+		e.Delta, e.OldTime.Format(time.RFC3339Nano), e.NewTime.Format(time.RFC3339Nano), e.Server, e.Method, e.User)
+}
+
+// AppendAudit appends the entry as a JSON line to the audit file.
+func AppendAudit(path string, entry AuditEntry) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// currentUser returns the invoking user, including the original user when run through sudo.
+func currentUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		name = fmt.Sprintf("%s (sudo by %s)", name, sudoUser)
+	}
+	return name
+}
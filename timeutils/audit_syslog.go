@@ -0,0 +1,16 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package timeutils
+
+import "log/syslog"
+
+// SyslogAudit writes the entry to the local syslog daemon under the AuditTag tag.
+func SyslogAudit(entry AuditEntry) error {
+	writer, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTHPRIV, AuditTag)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	return writer.Notice(entry.String())
+}
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package timeutils
+
+import "fmt"
+
+// SyslogAudit is not available on Windows.
+func SyslogAudit(_ AuditEntry) error {
+	return fmt.Errorf("syslog is not available on Windows")
+}
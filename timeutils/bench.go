@@ -0,0 +1,193 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BenchOptions controls how each server is benchmarked.
+type BenchOptions struct {
+	Queries  int           // queries sent to each server
+	Interval time.Duration // between the queries to one server
+}
+
+// BenchResult summarizes the queries sent to one server. Times are in seconds.
+type BenchResult struct {
+	Rank        int     `json:"rank"`
+	Server      string  `json:"server"`
+	Address     string  `json:"address,omitempty"`
+	Queries     int     `json:"queries"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+	Stratum     uint8   `json:"stratum,omitempty"`
+	RTTMin      float64 `json:"rtt_min_seconds"`
+	RTTMedian   float64 `json:"rtt_median_seconds"`
+	RTTP90      float64 `json:"rtt_p90_seconds"`
+	RTTMax      float64 `json:"rtt_max_seconds"`
+	Offset      float64 `json:"offset_median_seconds"`
+	Jitter      float64 `json:"offset_jitter_seconds"`
+	// Score is half the median round trip plus the offset jitter: how far a
+	// single measurement is expected to be off. Lower is better.
+	Score float64 `json:"score_seconds"`
+	Error string  `json:"error,omitempty"` // the last failure
+}
+
+var benchColumns = []string{"rank", "server", "address", "queries", "failures", "failure_rate", "stratum",
+	"rtt_min_seconds", "rtt_median_seconds", "rtt_p90_seconds", "rtt_max_seconds",
+	"offset_median_seconds", "offset_jitter_seconds", "score_seconds", "error"}
+
+// BenchServers queries every server opts.Queries times, the servers in
+// parallel, and returns the results ranked by failure rate, then by score.
+// Servers that answer with a kiss of death are not queried again.
+func BenchServers(servers []string, opts BenchOptions) []BenchResult {
+	results := make([]BenchResult, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			results[i] = benchServer(server, opts)
+		}(i, server)
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if (a.Failures == a.Queries) != (b.Failures == b.Queries) {
+			return b.Failures == b.Queries
+		}
+		if a.FailureRate != b.FailureRate {
+			return a.FailureRate < b.FailureRate
+		}
+		return a.Score < b.Score
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+	return results
+}
+
+func benchServer(server string, opts BenchOptions) BenchResult {
+	result := BenchResult{Server: server, Queries: opts.Queries}
+	address := server
+	if net.ParseIP(server) == nil {
+		ip, err := GetServerIP(server)
+		if err != nil {
+			result.Failures = opts.Queries
+			result.FailureRate = 1
+			result.Error = err.Error()
+			return result
+		}
+		address = ip
+	}
+	result.Address = address
+
+	var rtts, offsets []time.Duration
+	for i := 0; i < opts.Queries; i++ {
+		if i > 0 {
+			time.Sleep(opts.Interval)
+		}
+		ntpResult, err := queryNTPWithTimestamps(address)
+		if err == nil {
+			err = ValidateResponse(ntpResult)
+		}
+		if err != nil {
+			Logger.Info("bench query failed", "server", server, "query", i+1, "error", err)
+			result.Failures++
+			result.Error = err.Error()
+			var kod *KissOfDeathError
+			if errors.As(err, &kod) {
+				// The server asked to be left alone: the remaining queries count as failed.
+				result.Failures += opts.Queries - i - 1
+				break
+			}
+			continue
+		}
+		Logger.Info("bench query", "server", server, "query", i+1, "offset", ntpResult.ClockOffset, "rtt", ntpResult.RTT)
+		result.Stratum = ntpResult.Stratum
+		rtts = append(rtts, ntpResult.RTT)
+		offsets = append(offsets, ntpResult.ClockOffset)
+	}
+	result.FailureRate = float64(result.Failures) / float64(opts.Queries)
+	if len(rtts) == 0 {
+		return result
+	}
+
+	jitter := offsetJitter(offsets)
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	sort.Slice(rtts, func(i, j int) bool { return rtts[i] < rtts[j] })
+	percentile := func(sorted []time.Duration, p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1)+0.5)]
+	}
+	result.RTTMin = rtts[0].Seconds()
+	result.RTTMedian = percentile(rtts, 0.5).Seconds()
+	result.RTTP90 = percentile(rtts, 0.9).Seconds()
+	result.RTTMax = rtts[len(rtts)-1].Seconds()
+	result.Offset = percentile(offsets, 0.5).Seconds()
+	result.Jitter = jitter.Seconds()
+	result.Score = result.RTTMedian/2 + result.Jitter
+	return result
+}
+
+// FormatBench renders the ranked results as a table, or as lines with PlainOutput.
+func FormatBench(results []BenchResult) string {
+	ms := func(seconds float64) string {
+		return fmt.Sprintf("%.3f", seconds*1000)
+	}
+
+	var buf bytes.Buffer
+	if PlainOutput {
+		for _, r := range results {
+			fmt.Fprintf(&buf, "%d. %s: %d/%d failed", r.Rank, r.Server, r.Failures, r.Queries)
+			if r.Failures < r.Queries {
+				fmt.Fprintf(&buf, ", rtt min/median/p90/max %s/%s/%s/%s ms, offset %s ms, jitter %s ms, score %s ms",
+					ms(r.RTTMin), ms(r.RTTMedian), ms(r.RTTP90), ms(r.RTTMax), ms(r.Offset), ms(r.Jitter), ms(r.Score))
+			} else if r.Error != "" {
+				fmt.Fprintf(&buf, " (%s)", r.Error)
+			}
+			buf.WriteString("\n")
+		}
+		return buf.String()
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		row := []string{strconv.Itoa(r.Rank), r.Server, fmt.Sprintf("%.0f%%", r.FailureRate*100)}
+		if r.Failures == r.Queries {
+			row = append(row, "-", "-", "-", "-", "-", "-", "-")
+		} else {
+			row = append(row, ms(r.RTTMin), ms(r.RTTMedian), ms(r.RTTP90), ms(r.RTTMax), ms(r.Offset), ms(r.Jitter), ms(r.Score))
+		}
+		rows = append(rows, row)
+	}
+	renderTable(&buf, []string{"#", "Server", "Failed", "RTT Min", "RTT Median", "RTT P90", "RTT Max", "Offset", "Jitter", "Score"}, rows, tableStyle{noWrap: true})
+	buf.WriteString("Times in milliseconds; ranked by failure rate, then by score (median RTT / 2 + jitter).\n")
+	return buf.String()
+}
+
+// FormatBenchCSV renders the ranked results as CSV with a header line.
+func FormatBenchCSV(results []BenchResult) (string, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(benchColumns)
+	seconds := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	for _, r := range results {
+		writer.Write([]string{
+			strconv.Itoa(r.Rank), r.Server, r.Address, strconv.Itoa(r.Queries), strconv.Itoa(r.Failures),
+			seconds(r.FailureRate), strconv.Itoa(int(r.Stratum)),
+			seconds(r.RTTMin), seconds(r.RTTMedian), seconds(r.RTTP90), seconds(r.RTTMax),
+			seconds(r.Offset), seconds(r.Jitter), seconds(r.Score), r.Error,
+		})
+	}
+	writer.Flush()
+	return buf.String(), writer.Error()
+}
@@ -0,0 +1,68 @@
+package timeutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"time"
+)
+
+// LoadCertificates returns the certificate chain named by spec: a PEM file when
+// one exists at that path, otherwise the chain presented by the TLS server at HOST[:PORT].
+func LoadCertificates(spec string) ([]*x509.Certificate, error) {
+	if data, err := os.ReadFile(spec); err == nil {
+		return parsePEMCertificates(data)
+	}
+
+	addr, name := tlsAddress(spec)
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{
+		ServerName: name,
+		// Only the validity window is of interest, and it must not be judged by the local clock.
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates, nil
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// CheckCertificateTime is a plausibility check of a fetched time: it warns
+// (see Warnf) when t falls outside the validity window of any certificate in the chain.
+func CheckCertificateTime(certs []*x509.Certificate, t time.Time) error {
+	for _, cert := range certs {
+		switch {
+		case t.Before(cert.NotBefore):
+			return Warnf("fetched time %s is before certificate %q becomes valid (%s)", t.Format(time.RFC3339), cert.Subject.CommonName, cert.NotBefore.Format(time.RFC3339))
+		case t.After(cert.NotAfter):
+			return Warnf("fetched time %s is after certificate %q expired (%s)", t.Format(time.RFC3339), cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
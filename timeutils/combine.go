@@ -0,0 +1,101 @@
+package timeutils
+
+import (
+	"sort"
+	"time"
+)
+
+// Candidate is the measurement of one server for the clock selection.
+type Candidate struct {
+	Server string
+	Offset time.Duration
+	// Distance is the root distance: the true offset lies within Offset ± Distance.
+	Distance time.Duration
+	// Weight scales the share of the candidate in the combined offset.
+	Weight float64
+	// Prefer marks a candidate whose offset is used alone while it is a truechimer.
+	Prefer bool
+}
+
+// minDistance keeps a server that reports no distance at all from taking all
+// the weight of the combined offset.
+const minDistance = time.Microsecond
+
+// SelectClocks sorts the candidates into truechimers and falsetickers with the
+// intersection algorithm of RFC 5905: it finds the smallest interval that the
+// correctness intervals of a majority of the candidates have in common, and
+// the candidates whose interval misses it are falsetickers. ok is false when
+// no majority has an interval in common.
+func SelectClocks(candidates []Candidate) (truechimers []bool, ok bool) {
+	type edge struct {
+		at   time.Duration
+		kind int // +1 where an interval starts, -1 where it ends
+	}
+	var edges []edge
+	for _, c := range candidates {
+		edges = append(edges, edge{c.Offset - c.Distance, +1}, edge{c.Offset + c.Distance, -1})
+	}
+	// Intervals are closed, so where one starts and another ends at the same
+	// point, the start is counted first.
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].at != edges[j].at {
+			return edges[i].at < edges[j].at
+		}
+		return edges[i].kind > edges[j].kind
+	})
+
+	n := len(candidates)
+	for falsetickers := 0; 2*falsetickers < n; falsetickers++ {
+		needed := n - falsetickers
+		var low, high time.Duration
+		found := false
+		for i, count := 0, 0; i < len(edges); i++ {
+			if count += edges[i].kind; count >= needed {
+				low, found = edges[i].at, true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		for i, count := len(edges)-1, 0; i >= 0; i-- {
+			if count -= edges[i].kind; count >= needed {
+				high = edges[i].at
+				break
+			}
+		}
+		if low > high {
+			continue
+		}
+		truechimers = make([]bool, n)
+		for i, c := range candidates {
+			truechimers[i] = c.Offset-c.Distance <= high && c.Offset+c.Distance >= low
+		}
+		return truechimers, true
+	}
+	return nil, false
+}
+
+// CombineOffsets averages the offsets of the truechimers, each weighted by the
+// inverse of its root distance as in the clock combine algorithm of RFC 5905,
+// times its Weight. When preferred candidates are among the truechimers, only
+// they are combined; the others have only served to cross-check them.
+func CombineOffsets(candidates []Candidate, truechimers []bool) time.Duration {
+	preferred := false
+	for i, c := range candidates {
+		preferred = preferred || (truechimers[i] && c.Prefer)
+	}
+	var sum, weights float64
+	for i, c := range candidates {
+		if !truechimers[i] || (preferred && !c.Prefer) {
+			continue
+		}
+		weight := c.Weight / max(c.Distance, minDistance).Seconds()
+		sum += weight * c.Offset.Seconds()
+		weights += weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return time.Duration(sum / weights * float64(time.Second))
+}
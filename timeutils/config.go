@@ -0,0 +1,148 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// Set methods understood by PlatformDefaults.SetMethod.
+const (
+	SetMethodSyscall = "syscall"
+	SetMethodCommand = "command"
+	// SetMethodChrony hands the new time to a running chronyd instead of setting the clock directly.
+	SetMethodChrony = "chrony"
+	// SetMethodW32Time asks the Windows Time service to resynchronize instead of setting the clock directly.
+	SetMethodW32Time = "w32tm"
+)
+
+// IsSetMethod reports whether name is a known set method.
+func IsSetMethod(name string) bool {
+	switch name {
+	case SetMethodSyscall, SetMethodCommand, SetMethodChrony, SetMethodW32Time:
+		return true
+	}
+	return false
+}
+
+// DelegatesToDaemon reports whether the set method hands the change to a time
+// daemon, which is then expected to be running.
+func DelegatesToDaemon(method string) bool {
+	return method == SetMethodChrony || method == SetMethodW32Time
+}
+
+// PlatformDefaults holds the defaults that are chosen at runtime for the current platform.
+type PlatformDefaults struct {
+	Server    string `json:"server,omitempty"`
+	SetMethod string `json:"set_method,omitempty"`
+	Service   string `json:"service,omitempty"`
+	StateDir  string `json:"state_dir,omitempty"`
+}
+
+// MetricsConfig configures the Prometheus exporter.
+type MetricsConfig struct {
+	// OffsetBuckets and RTTBuckets accept a preset ("lan", "wan") or a comma-separated list of durations.
+	OffsetBuckets string `json:"offset_buckets,omitempty"`
+	RTTBuckets    string `json:"rtt_buckets,omitempty"`
+}
+
+// DaemonConfig holds the daemon mode settings that a SIGHUP re-reads, each
+// used unless the matching flag is given. Durations use Go syntax ("90s").
+type DaemonConfig struct {
+	Interval string `json:"interval,omitempty"`
+	// Schedule is a cron expression for the queries, used instead of Interval.
+	Schedule string `json:"schedule,omitempty"`
+	// QuietWindows are periods such as "Mon-Fri 09:30-16:00 America/New_York"
+	// during which the clock is measured but not stepped.
+	QuietWindows []string `json:"quiet_windows,omitempty"`
+	// PollMin and PollMax turn on adaptive polling between them, as durations
+	// or powers of two in seconds as in ntpd ("6" is 64s).
+	PollMin        string `json:"poll_min,omitempty"`
+	PollMax        string `json:"poll_max,omitempty"`
+	MaxOffset      string `json:"max_offset,omitempty"`
+	MinAdjust      string `json:"min_adjust,omitempty"`
+	AlertThreshold string `json:"alert_threshold,omitempty"`
+	AlertFailures  int    `json:"alert_failures,omitempty"`
+	// AlertWebhookFormat only applies to AlertWebhook, not to --alert-webhook.
+	AlertWebhook       string   `json:"alert_webhook,omitempty"`
+	AlertWebhookFormat string   `json:"alert_webhook_format,omitempty"`
+	AlertEmail         []string `json:"alert_email,omitempty"`
+	LogFile            string   `json:"log_file,omitempty"`
+	AuditFile          string   `json:"audit_file,omitempty"`
+}
+
+// ServerConfig holds the preferences for one NTP server, used by --combine.
+type ServerConfig struct {
+	// Weight scales the share of the server in the combined offset; nil is 1.
+	Weight *float64 `json:"weight,omitempty"`
+	// Prefer uses the offset of the server alone while it is not a falseticker.
+	Prefer bool `json:"prefer,omitempty"`
+}
+
+// Config is the on-disk configuration file.
+type Config struct {
+	// Platforms overrides the built-in defaults per GOOS ("linux", "windows", "darwin", ...).
+	Platforms map[string]PlatformDefaults `json:"platforms,omitempty"`
+	Metrics   MetricsConfig               `json:"metrics,omitempty"`
+	SMTP      SMTPConfig                  `json:"smtp,omitempty"`
+	// ChronySocket overrides the chronyd command socket used by the chrony set method.
+	ChronySocket string `json:"chrony_socket,omitempty"`
+	// ReadOnly disables every clock-mutating code path, regardless of flags.
+	ReadOnly bool `json:"read_only,omitempty"`
+	// LeapMode is how the clock is taken through leap seconds: "step" (the default) or "smear".
+	LeapMode string `json:"leap_mode,omitempty"`
+	// Servers holds per-server preferences, keyed by the server as given to --ntp-server.
+	Servers map[string]ServerConfig `json:"servers,omitempty"`
+	// Daemon configures daemon mode; the platform server is re-read with it on SIGHUP.
+	Daemon DaemonConfig `json:"daemon,omitempty"`
+}
+
+// LoadConfig reads a JSON configuration file. An empty path yields an empty configuration.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// ResolvePlatformDefaults returns the built-in defaults for the running platform
+// with any non-empty override from the configuration applied on top.
+func ResolvePlatformDefaults(cfg *Config) (PlatformDefaults, error) {
+	defaults := platformDefaults()
+
+	if cfg != nil {
+		override := cfg.Platforms[runtime.GOOS]
+		if override.Server != "" {
+			defaults.Server = override.Server
+		}
+		if override.SetMethod != "" {
+			defaults.SetMethod = override.SetMethod
+		}
+		if override.Service != "" {
+			defaults.Service = override.Service
+		}
+		if override.StateDir != "" {
+			defaults.StateDir = override.StateDir
+		}
+	}
+
+	if dir := os.Getenv(StateDirEnv); dir != "" {
+		defaults.StateDir = dir
+	}
+
+	if !IsSetMethod(defaults.SetMethod) {
+		return defaults, fmt.Errorf("unknown set method %q for %s", defaults.SetMethod, runtime.GOOS)
+	}
+
+	return defaults, nil
+}
@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"os"
+	"strings"
+)
+
+// cgroupMarkers are substrings of /proc/1/cgroup that betray a container runtime.
+var cgroupMarkers = []string{"docker", "kubepods", "containerd", "libpod", "lxc"}
+
+// DetectContainer reports whether the process appears to run inside a container,
+// where the clock is shared with the host, and which indicator gave it away.
+func DetectContainer() (bool, string) {
+	if runtime := os.Getenv("container"); runtime != "" {
+		return true, "container=" + runtime + " in the environment"
+	}
+	for _, marker := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(marker); err == nil {
+			return true, marker + " exists"
+		}
+	}
+	if data, err := os.ReadFile("/proc/1/cgroup"); err == nil {
+		for _, marker := range cgroupMarkers {
+			if strings.Contains(string(data), marker) {
+				return true, "/proc/1/cgroup mentions " + marker
+			}
+		}
+	}
+	if data, err := os.ReadFile("/proc/self/mountinfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) > 4 && fields[4] == "/" && strings.Contains(line, " - overlay ") {
+				return true, "root filesystem is an overlay mount"
+			}
+		}
+	}
+	return false, ""
+}
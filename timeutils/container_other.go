@@ -0,0 +1,9 @@
+//go:build !linux && !solaris
+// +build !linux,!solaris
+
+package timeutils
+
+// DetectContainer only recognizes Linux containers and illumos/Solaris zones.
+func DetectContainer() (bool, string) {
+	return false, ""
+}
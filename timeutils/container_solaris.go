@@ -0,0 +1,22 @@
+//go:build solaris
+// +build solaris
+
+package timeutils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// DetectContainer reports whether the process runs in a non-global zone, where
+// the clock is shared with the global zone.
+func DetectContainer() (bool, string) {
+	out, err := exec.Command("zonename").Output()
+	if err != nil {
+		return false, ""
+	}
+	if zone := strings.TrimSpace(string(out)); zone != "" && zone != "global" {
+		return true, "running in zone " + zone
+	}
+	return false, ""
+}
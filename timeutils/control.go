@@ -0,0 +1,129 @@
+//go:build !minimal
+// +build !minimal
+
+package timeutils
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"ntpcl/controlpb"
+)
+
+// ControlSupported reports whether this build includes the gRPC control API.
+const ControlSupported = true
+
+// controlServer implements the Control service.
+type controlServer struct {
+	controlpb.UnimplementedControlServer
+	status     *StatusAPI
+	controller Controller
+}
+
+// ControlServer is the gRPC server of the control API.
+type ControlServer = grpc.Server
+
+// ServeControl serves the gRPC control API on a unix socket, readable and
+// writable by the owner only, replacing a stale socket file. It returns the
+// server, to stop it on shutdown.
+func ServeControl(path string, s *StatusAPI, controller Controller) (*ControlServer, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	server := grpc.NewServer()
+	controlpb.RegisterControlServer(server, &controlServer{status: s, controller: controller})
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			Logger.Error("control API stopped", "error", err)
+		}
+	}()
+	Logger.Info("serving the control API", "socket", path)
+	return server, nil
+}
+
+func (c *controlServer) Status(_ context.Context, _ *controlpb.StatusRequest) (*controlpb.StatusResponse, error) {
+	s := c.status.Status(time.Now())
+	response := &controlpb.StatusResponse{
+		Healthy:             s.Healthy,
+		Reason:              s.Reason,
+		StartedAt:           timestamppb.New(s.StartedAt),
+		Interval:            durationpb.New(secondsDuration(s.Interval)),
+		OffsetThreshold:     durationpb.New(secondsDuration(s.Threshold)),
+		ConsecutiveFailures: uint32(s.ConsecutiveFailures),
+		LastError:           s.LastError,
+	}
+	if s.LastSync != nil {
+		response.LastSync = syncMessage(s.LastSync.LogRecord)
+	}
+	return response, nil
+}
+
+func (c *controlServer) ForceSync(ctx context.Context, _ *controlpb.ForceSyncRequest) (*controlpb.ForceSyncResponse, error) {
+	report, err := c.controller.ForceSync(ctx)
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &controlpb.ForceSyncResponse{Result: syncMessage(ReportRecord(report))}, nil
+}
+
+func (c *controlServer) SetConfig(ctx context.Context, req *controlpb.SetConfigRequest) (*controlpb.SetConfigResponse, error) {
+	change := ConfigChange{Server: req.Server}
+	for _, field := range []struct {
+		value *durationpb.Duration
+		dst   **time.Duration
+	}{
+		{req.Interval, &change.Interval},
+		{req.MaxOffset, &change.MaxOffset},
+		{req.MinAdjust, &change.MinAdjust},
+		{req.AlertThreshold, &change.AlertThreshold},
+	} {
+		if field.value == nil {
+			continue
+		}
+		if err := field.value.CheckValid(); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		d := field.value.AsDuration()
+		*field.dst = &d
+	}
+	changes, err := c.controller.SetConfig(ctx, change)
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &controlpb.SetConfigResponse{Changes: changes}, nil
+}
+
+// syncMessage converts a log record to its message.
+func syncMessage(record LogRecord) *controlpb.Sync {
+	return &controlpb.Sync{
+		Time:     timestamppb.New(record.Timestamp),
+		Method:   record.Method,
+		Server:   record.Server,
+		Offset:   durationpb.New(secondsDuration(record.Offset)),
+		Rtt:      durationpb.New(secondsDuration(record.RTT)),
+		Stratum:  uint32(record.Stratum),
+		ClockSet: record.ClockSet,
+	}
+}
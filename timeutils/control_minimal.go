@@ -0,0 +1,21 @@
+//go:build minimal
+// +build minimal
+
+package timeutils
+
+import "fmt"
+
+// ControlSupported reports whether this build includes the gRPC control API.
+// The minimal build leaves out gRPC.
+const ControlSupported = false
+
+// ControlServer stands in for the gRPC server of the full build.
+type ControlServer struct{}
+
+// Stop does nothing.
+func (*ControlServer) Stop() {}
+
+// ServeControl is not available in the minimal build.
+func ServeControl(_ string, _ *StatusAPI, _ Controller) (*ControlServer, error) {
+	return nil, fmt.Errorf("the control API is not included in the minimal build")
+}
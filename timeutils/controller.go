@@ -0,0 +1,24 @@
+package timeutils
+
+import (
+	"context"
+	"time"
+)
+
+// ConfigChange holds the daemon settings a SetConfig request changes; nil
+// fields are kept.
+type ConfigChange struct {
+	Server         *string
+	Interval       *time.Duration
+	MaxOffset      *time.Duration
+	MinAdjust      *time.Duration
+	AlertThreshold *time.Duration
+}
+
+// Controller carries out the requests of the control API in the daemon loop.
+type Controller interface {
+	// ForceSync runs a query now and returns its result.
+	ForceSync(ctx context.Context) (Report, error)
+	// SetConfig applies a change and lists the settings that changed.
+	SetConfig(ctx context.Context, change ConfigChange) ([]string, error)
+}
@@ -0,0 +1,18 @@
+//go:build freebsd || openbsd || netbsd
+// +build freebsd openbsd netbsd
+
+package timeutils
+
+import "os/exec"
+
+// DetectTimeDaemons returns the time daemons found running: ntpd from the
+// base system or OpenNTPD, and chronyd when installed.
+func DetectTimeDaemons() []string {
+	var daemons []string
+	for _, name := range []string{"ntpd", "openntpd", "chronyd"} {
+		if exec.Command("pgrep", "-x", name).Run() == nil {
+			daemons = append(daemons, name)
+		}
+	}
+	return daemons
+}
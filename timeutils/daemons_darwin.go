@@ -0,0 +1,34 @@
+//go:build darwin
+// +build darwin
+
+package timeutils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// DetectTimeDaemons returns the time daemons found running: timed, the macOS
+// default, when "Set time and date automatically" is on, and ntpd or chronyd
+// when installed.
+func DetectTimeDaemons() []string {
+	var daemons []string
+	for _, name := range []string{"timed", "ntpd", "chronyd"} {
+		if exec.Command("pgrep", "-x", name).Run() != nil {
+			continue
+		}
+		if name == "timed" && !usingNetworkTime() {
+			continue
+		}
+		daemons = append(daemons, name)
+	}
+	return daemons
+}
+
+// usingNetworkTime reports whether timed syncs the clock. timed runs even
+// when network time is turned off; when the setting cannot be read, it is
+// assumed to be on.
+func usingNetworkTime() bool {
+	out, err := exec.Command("systemsetup", "-getusingnetworktime").Output()
+	return err != nil || !strings.Contains(string(out), "Off")
+}
@@ -0,0 +1,89 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// timeDaemons maps /proc/<pid>/comm values (truncated to 15 bytes) to daemon names.
+var timeDaemons = map[string]string{
+	"ntpd":            "ntpd",
+	"chronyd":         "chronyd",
+	"systemd-timesyn": "systemd-timesyncd",
+	"openntpd":        "openntpd",
+	"phc2sys":         "phc2sys",
+}
+
+// daemonSockets are control sockets and pidfiles left by time daemons.
+var daemonSockets = map[string]string{
+	"/run/chrony/chronyd.sock": "chronyd",
+	"/run/chrony/chronyd.pid":  "chronyd",
+	"/run/ntpd.pid":            "ntpd",
+	"/var/run/ntpd.pid":        "ntpd",
+}
+
+// pidfileRuns reports whether the process named in the pidfile at path is
+// still running as the daemon name.
+func pidfileRuns(path, name string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	comm, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	return err == nil && timeDaemons[strings.TrimSpace(string(comm))] == name
+}
+
+// staUnsync is the adjtimex status bit the kernel clears while a daemon keeps the clock synchronized.
+const staUnsync = 0x0040
+
+// DetectTimeDaemons returns a description of every NTP daemon or clock discipline
+// found running: matching processes, their sockets and pidfiles, and the kernel's
+// synchronization status.
+func DetectTimeDaemons() []string {
+	found := map[string]string{}
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	for _, path := range comms {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if name, ok := timeDaemons[strings.TrimSpace(string(data))]; ok {
+			found[name] = name + " (pid " + filepath.Base(filepath.Dir(path)) + ")"
+		}
+	}
+	for path, name := range daemonSockets {
+		if _, ok := found[name]; ok {
+			continue
+		}
+		if strings.HasSuffix(path, ".pid") {
+			// A pidfile outlives a daemon that crashed or was killed.
+			if pidfileRuns(path, name) {
+				found[name] = name + " (" + path + ")"
+			}
+		} else if _, err := os.Stat(path); err == nil {
+			found[name] = name + " (" + path + ")"
+		}
+	}
+
+	var daemons []string
+	for _, description := range found {
+		daemons = append(daemons, description)
+	}
+	sort.Strings(daemons)
+	var timex syscall.Timex
+	if _, err := syscall.Adjtimex(&timex); err == nil && timex.Status&staUnsync == 0 {
+		daemons = append(daemons, "kernel clock discipline (adjtimex reports synchronized)")
+	}
+	return daemons
+}
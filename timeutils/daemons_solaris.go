@@ -0,0 +1,18 @@
+//go:build solaris
+// +build solaris
+
+package timeutils
+
+import "os/exec"
+
+// DetectTimeDaemons returns the time daemons found running: ntpd from
+// svc:/network/ntp, and chronyd when installed.
+func DetectTimeDaemons() []string {
+	var daemons []string
+	for _, name := range []string{"ntpd", "chronyd"} {
+		if exec.Command("pgrep", "-x", name).Run() == nil {
+			daemons = append(daemons, name)
+		}
+	}
+	return daemons
+}
@@ -0,0 +1,26 @@
+//go:build windows
+// +build windows
+
+package timeutils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// DetectTimeDaemons reports the Windows Time service when it is running and
+// syncs the clock from a time source. The service also runs on machines that
+// only keep the local clock, which then report it as their source.
+func DetectTimeDaemons() []string {
+	out, err := exec.Command("sc", "query", "w32time").Output()
+	if err != nil || !strings.Contains(string(out), "RUNNING") {
+		return nil
+	}
+	if out, err := exec.Command("w32tm", "/query", "/source").Output(); err == nil {
+		source := strings.TrimSpace(string(out))
+		if source == "Local CMOS Clock" || source == "Free-running System Clock" {
+			return nil
+		}
+	}
+	return []string{"Windows Time service (w32time)"}
+}
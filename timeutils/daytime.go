@@ -0,0 +1,134 @@
+package timeutils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nistDaytime matches NIST's daytime format: "JJJJJ YY-MM-DD HH:MM:SS TT L H msADV UTC(NIST) *".
+var nistDaytime = regexp.MustCompile(`^\d{5} (\d{2}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) \d{2} \d (\d) +[\d.]+ UTC\(NIST\)`)
+
+// daytimeLayouts are the layouts tried, in order, for responses that are not in NIST format.
+// RFC 867 suggests "Weekday, Month Day, Year Time-Zone" and "dd mmm yy hh:mm:ss zzz".
+var daytimeLayouts = []string{
+	"Mon Jan 2 15:04:05 2006",
+	time.ANSIC,
+	time.UnixDate,
+	time.RubyDate,
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC850,
+	time.RFC822,
+	time.RFC822Z,
+	time.RFC3339Nano,
+	"Monday, January 2, 2006 15:04:05-MST",
+	"02 Jan 06 15:04:05 MST",
+	"02 Jan 2006 15:04:05 MST",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"Mon Jan 2 15:04:05 MST 2006",
+}
+
+// DaytimeOptions configures how daytime responses are read.
+type DaytimeOptions struct {
+	// Format is a Go layout or strptime-style format (containing %) for servers
+	// with a non-standard response; the format is detected when empty.
+	Format string
+	// Location is used for responses without zone information; UTC when nil.
+	Location *time.Location
+	// UDP queries the server with an empty datagram instead of over TCP.
+	UDP bool
+}
+
+// strptimeDirectives maps strptime directives to Go layout elements.
+var strptimeDirectives = map[byte]string{
+	'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'e': "_2", 'j': "002",
+	'H': "15", 'I': "03", 'M': "04", 'S': "05", 'f': "000000", 'p': "PM",
+	'b': "Jan", 'h': "Jan", 'B': "January", 'a': "Mon", 'A': "Monday",
+	'Z': "MST", 'z': "-0700", 'T': "15:04:05", 'D': "01/02/06", 'F': "2006-01-02",
+	'%': "%",
+}
+
+// DaytimeLayout returns the Go layout for format, converting strptime-style
+// formats (those containing %) and passing Go layouts through.
+func DaytimeLayout(format string) (string, error) {
+	if !strings.Contains(format, "%") {
+		return format, nil
+	}
+
+	var layout strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			layout.WriteByte(format[i])
+			continue
+		}
+		if i++; i == len(format) {
+			return "", fmt.Errorf("format %q ends with %%", format)
+		}
+		element, ok := strptimeDirectives[format[i]]
+		if !ok {
+			return "", fmt.Errorf("unsupported directive %%%c in %q", format[i], format)
+		}
+		layout.WriteString(element)
+	}
+	return layout.String(), nil
+}
+
+// parse parses a daytime response with the configured format, or detects it.
+func (o DaytimeOptions) parse(response string) (time.Time, error) {
+	loc := o.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	if o.Format == "" {
+		return detectDaytimeFormat(response, loc)
+	}
+
+	layout, err := DaytimeLayout(o.Format)
+	if err != nil {
+		return time.Time{}, err
+	}
+	serverTime, err := time.ParseInLocation(layout, response, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("daytime response %q does not match --daytime-format: %v", response, err)
+	}
+	return serverTime, nil
+}
+
+// detectDaytimeFormat parses a daytime response in any of the layouts ntpcl
+// recognizes. Times without zone information are taken to be in loc.
+func detectDaytimeFormat(response string, loc *time.Location) (time.Time, error) {
+	if m := nistDaytime.FindStringSubmatch(response); m != nil {
+		serverTime, err := time.Parse("06-01-02 15:04:05", m[1])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if health, _ := strconv.Atoi(m[2]); health != 0 {
+			if err := Warnf("NIST server reports health %d (0 is healthy)", health); err != nil {
+				return time.Time{}, err
+			}
+		}
+		return serverTime, nil
+	}
+
+	for _, layout := range daytimeLayouts {
+		if serverTime, err := time.ParseInLocation(layout, response, loc); err == nil {
+			return serverTime, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized daytime format %q", response)
+}
+
+// daytimeLine returns the first non-empty line of a daytime response; NIST
+// servers send an empty line first.
+func daytimeLine(response string) string {
+	for _, line := range strings.Split(response, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
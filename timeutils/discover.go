@@ -0,0 +1,57 @@
+package timeutils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// DiscoverADTimeSource finds the PDC emulator of an Active Directory domain, the
+// root of the NT5DS time hierarchy, through the _ldap._tcp.pdc._msdcs SRV record.
+// An empty domain is taken from the local machine.
+func DiscoverADTimeSource(domain string) (string, error) {
+	if domain == "" {
+		domain = LocalDomain()
+	}
+	if domain == "" {
+		return "", errors.New("cannot determine the AD domain of this machine; pass it explicitly")
+	}
+
+	name := "_ldap._tcp.pdc._msdcs." + strings.TrimSuffix(domain, ".")
+	_, records, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up %s: %v", name, err)
+	}
+	if len(records) == 0 {
+		return "", fmt.Errorf("no SRV records for %s", name)
+	}
+	// LookupSRV sorts by priority and randomizes by weight.
+	Logger.Info("discovered AD time source", "record", name, "target", records[0].Target, "candidates", len(records))
+	return strings.TrimSuffix(records[0].Target, "."), nil
+}
+
+// LocalDomain returns the DNS domain of this machine: USERDNSDOMAIN on Windows,
+// otherwise the domain or first search entry of /etc/resolv.conf.
+func LocalDomain() string {
+	if domain := os.Getenv("USERDNSDOMAIN"); domain != "" {
+		return strings.ToLower(domain)
+	}
+
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && (fields[0] == "domain" || fields[0] == "search") {
+			return fields[1]
+		}
+	}
+	return ""
+}
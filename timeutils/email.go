@@ -0,0 +1,74 @@
+package timeutils
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig holds the mail server settings used for email alerts.
+type SMTPConfig struct {
+	Host     string `json:"host,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	From     string `json:"from,omitempty"`
+}
+
+// EmailSink sends alerts as plain-text email over SMTP.
+type EmailSink struct {
+	SMTP SMTPConfig
+	To   []string
+}
+
+// NewEmailSink creates a sink that mails alerts to the given recipients.
+func NewEmailSink(cfg SMTPConfig, to []string) (*EmailSink, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("email alerts need smtp.host in the config file")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 25
+	}
+	if cfg.From == "" {
+		cfg.From = "ntpcl@localhost"
+	}
+	return &EmailSink{SMTP: cfg, To: to}, nil
+}
+
+// Send implements AlertSink.
+func (s *EmailSink) Send(alert Alert) error {
+	addr := net.JoinHostPort(s.SMTP.Host, strconv.Itoa(s.SMTP.Port))
+
+	var auth smtp.Auth
+	if s.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", s.SMTP.Username, s.SMTP.Password, s.SMTP.Host)
+	}
+
+	return smtp.SendMail(addr, auth, s.SMTP.From, s.To, s.message(alert))
+}
+
+func (s *EmailSink) message(alert Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.SMTP.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&b, "Subject: [ntpcl] %s on %s: %s\r\n", strings.ToUpper(alert.Severity), alert.Hostname, alert.Kind)
+	fmt.Fprintf(&b, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&b, "%s\r\n\r\n", alert.Message)
+	fmt.Fprintf(&b, "Host:     %s\r\n", alert.Hostname)
+	fmt.Fprintf(&b, "Server:   %s\r\n", alert.Server)
+	if alert.Kind == AlertOffset {
+		fmt.Fprintf(&b, "Offset:   %v\r\n", time.Duration(alert.Offset*float64(time.Second)))
+		fmt.Fprintf(&b, "Limit:    %v\r\n", time.Duration(alert.Threshold*float64(time.Second)))
+	}
+	if alert.Failures > 0 {
+		fmt.Fprintf(&b, "Failures: %d\r\n", alert.Failures)
+		fmt.Fprintf(&b, "Error:    %s\r\n", alert.Error)
+	}
+	fmt.Fprintf(&b, "Time:     %s\r\n", alert.Time.Format(time.RFC3339))
+	return []byte(b.String())
+}
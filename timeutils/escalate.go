@@ -0,0 +1,33 @@
+package timeutils
+
+import "os"
+
+// Escalation commands that run the command set method's date with root privileges.
+const (
+	EscalateSudo   = "sudo"
+	EscalateDoas   = "doas"
+	EscalatePkexec = "pkexec"
+	// EscalateNone runs date as is, e.g. from a root shell or a setuid wrapper.
+	EscalateNone = "none"
+)
+
+// Escalate is the escalation command used by the command set method.
+var Escalate = EscalateSudo
+
+// IsEscalateCommand reports whether name is a known escalation command.
+func IsEscalateCommand(name string) bool {
+	switch name {
+	case EscalateSudo, EscalateDoas, EscalatePkexec, EscalateNone:
+		return true
+	}
+	return false
+}
+
+// escalated prefixes a command with Escalate, unless escalation is disabled
+// or the process already runs as root, where sudo or doas may not even exist.
+func escalated(args ...string) []string {
+	if Escalate == EscalateNone || os.Geteuid() == 0 {
+		return args
+	}
+	return append([]string{Escalate}, args...)
+}
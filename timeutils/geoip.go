@@ -0,0 +1,135 @@
+package timeutils
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoDB looks up addresses in local MaxMind-format databases, such as
+// GeoLite2-Country, GeoLite2-City and GeoLite2-ASN or their DB-IP equivalents.
+type GeoDB struct {
+	readers []*maxminddb.Reader
+}
+
+// GeoInfo is where an address is and which network it belongs to, as far as
+// the databases know.
+type GeoInfo struct {
+	Country     string `json:"country,omitempty"` // ISO 3166-1 alpha-2 code
+	CountryName string `json:"country_name,omitempty"`
+	Continent   string `json:"continent,omitempty"`
+	ASN         uint   `json:"asn,omitempty"`
+	ASOrg       string `json:"as_org,omitempty"`
+}
+
+// geoRecord holds the fields of the country, city and ASN databases.
+type geoRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Continent struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"continent"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// OpenGeoDB opens the databases; their answers are combined, so a country and
+// an ASN database can be used together.
+func OpenGeoDB(paths []string) (*GeoDB, error) {
+	db := &GeoDB{}
+	for _, path := range paths {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		db.readers = append(db.readers, reader)
+	}
+	return db, nil
+}
+
+// Close closes the databases. A nil *GeoDB has nothing to close.
+func (db *GeoDB) Close() {
+	if db == nil {
+		return
+	}
+	for _, reader := range db.readers {
+		reader.Close()
+	}
+}
+
+// Lookup returns what the databases know about the host of server: an IP
+// address, a host name, or a URL. A host name is resolved with ResolveServer,
+// so the address is the one the sources query first and a replay takes it
+// from the session. It returns nil when the databases know nothing.
+func (db *GeoDB) Lookup(server string) (*GeoInfo, error) {
+	if db == nil {
+		return nil, nil
+	}
+	host := server
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		host = u.Hostname()
+	} else if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		addresses, err := ResolveServer(host)
+		if err != nil {
+			return nil, err
+		}
+		ip = net.ParseIP(addresses[0])
+	}
+
+	var info GeoInfo
+	for _, reader := range db.readers {
+		var record geoRecord
+		if err := reader.Lookup(ip, &record); err != nil {
+			return nil, err
+		}
+		if record.Country.ISOCode != "" {
+			info.Country = record.Country.ISOCode
+			info.CountryName = record.Country.Names["en"]
+		}
+		if name := record.Continent.Names["en"]; name != "" {
+			info.Continent = name
+		}
+		if record.ASN != 0 {
+			info.ASN, info.ASOrg = record.ASN, record.ASOrg
+		}
+	}
+	if info == (GeoInfo{}) {
+		return nil, nil
+	}
+	return &info, nil
+}
+
+// Location describes the country and continent, e.g. "DE (Germany, Europe)".
+func (g GeoInfo) Location() string {
+	var names []string
+	for _, name := range []string{g.CountryName, g.Continent} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	switch {
+	case g.Country == "":
+		return strings.Join(names, ", ")
+	case len(names) == 0:
+		return g.Country
+	}
+	return fmt.Sprintf("%s (%s)", g.Country, strings.Join(names, ", "))
+}
+
+// Network describes the autonomous system, e.g. "AS3320 Deutsche Telekom AG".
+func (g GeoInfo) Network() string {
+	if g.ASN == 0 {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("AS%d %s", g.ASN, g.ASOrg))
+}
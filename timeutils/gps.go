@@ -0,0 +1,195 @@
+package timeutils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nmeaJitter is the assumed uncertainty of NMEA time: receivers send the
+// sentences some time after the second they describe, with varying delay.
+const nmeaJitter = 100 * time.Millisecond
+
+// GPSOptions configures a GPS receiver that reports the time in NMEA sentences.
+type GPSOptions struct {
+	Device string
+	Baud   int // 0 leaves the serial line settings alone
+	// Latency is the delay between the start of the second and the end of
+	// each sentence type ("RMC", "ZDA"), as measured for the receiver.
+	Latency map[string]time.Duration
+	Timeout time.Duration
+}
+
+// ParseSentenceLatency parses a per-sentence latency such as "RMC=350ms".
+func ParseSentenceLatency(s string) (string, time.Duration, error) {
+	sentence, value, ok := strings.Cut(s, "=")
+	sentence = strings.ToUpper(strings.TrimSpace(sentence))
+	if !ok || (sentence != "RMC" && sentence != "ZDA") {
+		return "", 0, fmt.Errorf("%q is not SENTENCE=DURATION with sentence RMC or ZDA", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return "", 0, err
+	}
+	return sentence, d, nil
+}
+
+// FetchTimeFromGPS reads NMEA sentences from a GPS receiver until a valid RMC
+// or ZDA sentence arrives and returns the time it reports, corrected for the
+// sentence latency, together with its uncertainty.
+func FetchTimeFromGPS(opts GPSOptions) (time.Time, time.Duration, error) {
+	device, err := os.OpenFile(opts.Device, os.O_RDWR, 0)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	defer device.Close()
+	if err := configureSerial(device, opts.Baud); err != nil {
+		return time.Time{}, 0, fmt.Errorf("failed to configure %s: %v", opts.Device, err)
+	}
+
+	type fix struct {
+		time     time.Time
+		sentence string
+		received time.Time
+	}
+	fixes := make(chan fix, 1)
+	errs := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(device)
+		for scanner.Scan() {
+			received := time.Now()
+			line := strings.TrimSpace(scanner.Text())
+			logTrace("nmea sentence", "device", opts.Device, "sentence", line)
+			t, sentence, err := parseNMEATime(line)
+			if err != nil {
+				Logger.Debug("skipped NMEA sentence", "sentence", line, "error", err)
+				continue
+			}
+			if sentence == "" {
+				continue
+			}
+			fixes <- fix{t, sentence, received}
+			return
+		}
+		if err := scanner.Err(); err != nil {
+			errs <- err
+			return
+		}
+		errs <- fmt.Errorf("%s closed before a time sentence was received", opts.Device)
+	}()
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	select {
+	case f := <-fixes:
+		// The receiver finished the sentence Latency after the second it names.
+		t := f.time.Add(opts.Latency[f.sentence]).Add(time.Since(f.received))
+		Logger.Info("GPS time", "device", opts.Device, "sentence", f.sentence, "time", f.time, "latency", opts.Latency[f.sentence])
+		return t, nmeaJitter, nil
+	case err := <-errs:
+		return time.Time{}, 0, err
+	case <-time.After(timeout):
+		return time.Time{}, 0, fmt.Errorf("no valid RMC or ZDA sentence from %s within %v", opts.Device, timeout)
+	}
+}
+
+// parseNMEATime returns the time of an RMC or ZDA sentence and the sentence
+// type. Other valid sentences return an empty type; RMC sentences without a
+// fix and corrupt sentences return an error.
+func parseNMEATime(line string) (time.Time, string, error) {
+	fields, err := nmeaFields(line)
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	if len(fields[0]) < 5 {
+		return time.Time{}, "", nil
+	}
+	sentence := fields[0][len(fields[0])-3:] // the type without the talker, e.g. GNRMC -> RMC
+
+	switch sentence {
+	case "RMC":
+		// $GPRMC,hhmmss.ss,A,lat,N,lon,E,speed,course,ddmmyy,...
+		if len(fields) < 10 {
+			return time.Time{}, "", fmt.Errorf("short RMC sentence")
+		}
+		if fields[2] != "A" {
+			return time.Time{}, "", fmt.Errorf("receiver has no fix")
+		}
+		date := fields[9]
+		if len(date) != 6 {
+			return time.Time{}, "", fmt.Errorf("invalid RMC date %q", date)
+		}
+		day, _ := strconv.Atoi(date[0:2])
+		month, _ := strconv.Atoi(date[2:4])
+		year, _ := strconv.Atoi(date[4:6])
+		// NMEA years have two digits; GPS receivers appeared long after 1980.
+		if year < 80 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+		t, err := nmeaTimeOfDay(fields[1], year, month, day)
+		return t, sentence, err
+	case "ZDA":
+		// $GPZDA,hhmmss.ss,dd,mm,yyyy,zone hours,zone minutes
+		if len(fields) < 5 {
+			return time.Time{}, "", fmt.Errorf("short ZDA sentence")
+		}
+		day, err1 := strconv.Atoi(fields[2])
+		month, err2 := strconv.Atoi(fields[3])
+		year, err3 := strconv.Atoi(fields[4])
+		if err1 != nil || err2 != nil || err3 != nil {
+			return time.Time{}, "", fmt.Errorf("invalid ZDA date")
+		}
+		t, err := nmeaTimeOfDay(fields[1], year, month, day)
+		return t, sentence, err
+	}
+	return time.Time{}, "", nil
+}
+
+// nmeaFields verifies the checksum of a sentence and splits it into fields,
+// the first of which is the talker and sentence type without the "$".
+func nmeaFields(line string) ([]string, error) {
+	if !strings.HasPrefix(line, "$") {
+		return nil, fmt.Errorf("not an NMEA sentence")
+	}
+	body, checksum, ok := strings.Cut(line[1:], "*")
+	if !ok {
+		return nil, fmt.Errorf("missing checksum")
+	}
+	want, err := strconv.ParseUint(checksum, 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum %q", checksum)
+	}
+	var sum byte
+	for i := 0; i < len(body); i++ {
+		sum ^= body[i]
+	}
+	if sum != byte(want) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	return strings.Split(body, ","), nil
+}
+
+// nmeaTimeOfDay combines an hhmmss.ss UTC time of day with a date.
+func nmeaTimeOfDay(hhmmss string, year, month, day int) (time.Time, error) {
+	if len(hhmmss) < 6 {
+		return time.Time{}, fmt.Errorf("invalid time %q", hhmmss)
+	}
+	hour, err1 := strconv.Atoi(hhmmss[0:2])
+	minute, err2 := strconv.Atoi(hhmmss[2:4])
+	seconds, err3 := strconv.ParseFloat(hhmmss[4:], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", hhmmss)
+	}
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, fmt.Errorf("invalid date %04d-%02d-%02d", year, month, day)
+	}
+	nanos := time.Duration(seconds * float64(time.Second))
+	return time.Date(year, time.Month(month), day, hour, minute, 0, 0, time.UTC).Add(nanos), nil
+}
@@ -0,0 +1,199 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// holdoverMinSpan is the shortest time between measurements a frequency is
+	// estimated over; over shorter spans the measurement noise dominates.
+	holdoverMinSpan = time.Minute
+	// holdoverSamples is the number of frequency estimates averaged.
+	holdoverSamples = 8
+	// holdoverMinUncertainty is the least uncertainty assumed for the
+	// frequency, in s/s: crystals wander by about 1 PPM with temperature.
+	holdoverMinUncertainty = 1e-6
+	// holdoverStateFile keeps the frequency estimates across restarts.
+	holdoverStateFile = "holdover.json"
+)
+
+// HoldoverStatus describes the clock while no source is reachable.
+type HoldoverStatus struct {
+	Since          time.Time `json:"since"`
+	Frequency      float64   `json:"frequency_ppm"` // positive when the local clock runs slow
+	Corrected      float64   `json:"corrected_seconds"`
+	EstimatedError float64   `json:"estimated_error_seconds"`
+}
+
+// Holdover estimates the frequency error of the local clock from successful
+// measurements, to keep correcting it while every source fails.
+type Holdover struct {
+	mu       sync.Mutex
+	path     string
+	lastSync time.Time // local time of the last measurement
+	// anchor is the local time of the measurement the next frequency
+	// estimate spans from, anchorOffset the offset left after it (zero when
+	// the clock was set) and anchorCorrected the correction applied since.
+	anchor          time.Time
+	anchorOffset    time.Duration
+	anchorCorrected time.Duration
+	frequencies     []float64     // recent estimates, in s/s
+	since           time.Time     // start of the holdover, zero while synchronized
+	corrected       time.Duration // correction applied since lastSync
+	correctedAt     time.Time
+}
+
+// holdoverState is what a Holdover keeps in the state directory.
+type holdoverState struct {
+	Frequencies     []float64     `json:"frequencies"`
+	LastSync        time.Time     `json:"last_sync"`
+	Anchor          time.Time     `json:"anchor"`
+	AnchorOffset    time.Duration `json:"anchor_offset"`
+	AnchorCorrected time.Duration `json:"anchor_corrected"`
+}
+
+// LoadHoldover reads the frequency estimates and the measurement they
+// continue from out of the state directory.
+func LoadHoldover(dir string) (*Holdover, error) {
+	h := &Holdover{path: filepath.Join(dir, holdoverStateFile)}
+	data, err := os.ReadFile(h.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state holdoverState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("corrupt state file: %v", err)
+	}
+	h.frequencies, h.lastSync = state.Frequencies, state.LastSync
+	h.anchor, h.anchorOffset, h.anchorCorrected = state.Anchor, state.AnchorOffset, state.AnchorCorrected
+	h.correctedAt = state.LastSync
+	return h, nil
+}
+
+// Save writes the frequency estimates to the state directory, if they are
+// kept there.
+func (h *Holdover) Save() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(holdoverState{
+		Frequencies:     h.frequencies,
+		LastSync:        h.lastSync,
+		Anchor:          h.anchor,
+		AnchorOffset:    h.anchorOffset,
+		AnchorCorrected: h.anchorCorrected,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, data, 0o600)
+}
+
+// Observe takes a measurement into account and ends a holdover, returning
+// how long it lasted.
+func (h *Holdover) Observe(report Report) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now, offset := report.LocalTime, report.TimeDifference()
+	span := now.Sub(h.anchor)
+	if !h.anchor.IsZero() && span >= holdoverMinSpan {
+		// The clock drifted by what is left plus what holdover took away.
+		drift := offset - h.anchorOffset + h.anchorCorrected
+		h.frequencies = append(h.frequencies, drift.Seconds()/span.Seconds())
+		if len(h.frequencies) > holdoverSamples {
+			h.frequencies = h.frequencies[1:]
+		}
+	}
+	// Measurements closer than holdoverMinSpan keep the anchor, so that
+	// frequent ones still add up to a span an estimate can be taken over.
+	// Setting the clock moves it regardless.
+	if h.anchor.IsZero() || span >= holdoverMinSpan || report.ClockSet {
+		h.anchor, h.anchorOffset, h.anchorCorrected = now, offset, 0
+		if report.ClockSet {
+			h.anchorOffset = 0
+		}
+	}
+	var lasted time.Duration
+	if !h.since.IsZero() {
+		lasted = now.Sub(h.since)
+	}
+	h.lastSync = now
+	h.since, h.corrected, h.correctedAt = time.Time{}, 0, now
+	return lasted
+}
+
+// frequency returns the mean of the estimates and its uncertainty.
+func (h *Holdover) frequency() (float64, float64, bool) {
+	if len(h.frequencies) == 0 {
+		return 0, 0, false
+	}
+	var sum float64
+	for _, f := range h.frequencies {
+		sum += f
+	}
+	mean := sum / float64(len(h.frequencies))
+	var squares float64
+	for _, f := range h.frequencies {
+		squares += (f - mean) * (f - mean)
+	}
+	return mean, max(math.Sqrt(squares/float64(len(h.frequencies))), holdoverMinUncertainty), true
+}
+
+// Enter starts or continues a holdover after every source failed. It returns
+// the correction due since the last one for the estimated frequency, or
+// false when there is no estimate yet.
+func (h *Holdover) Enter(now time.Time) (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	freq, _, ok := h.frequency()
+	if !ok {
+		return 0, false
+	}
+	if h.since.IsZero() {
+		h.since = now
+	}
+	return time.Duration(freq * float64(now.Sub(h.correctedAt))), true
+}
+
+// Applied records that the clock was corrected at now.
+func (h *Holdover) Applied(correction time.Duration, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.corrected += correction
+	h.anchorCorrected += correction
+	h.correctedAt = now
+}
+
+// Status returns the holdover status as of now, or nil outside a holdover.
+// The estimated error is the drift not corrected yet, plus what the
+// uncertainty of the frequency adds up to since the last measurement.
+func (h *Holdover) Status(now time.Time) *HoldoverStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	freq, uncertainty, ok := h.frequency()
+	if h.since.IsZero() || !ok {
+		return nil
+	}
+	elapsed := now.Sub(h.lastSync).Seconds()
+	return &HoldoverStatus{
+		Since:          h.since.UTC(),
+		Frequency:      freq * 1e6,
+		Corrected:      h.corrected.Seconds(),
+		EstimatedError: math.Abs(freq*elapsed-h.corrected.Seconds()) + uncertainty*elapsed,
+	}
+}
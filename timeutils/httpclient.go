@@ -0,0 +1,245 @@
+package timeutils
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTP methods accepted by HTTPOptions.Method.
+const (
+	HTTPMethodAuto = "auto" // HEAD, falling back to GET
+	HTTPMethodHead = "HEAD"
+	HTTPMethodGet  = "GET"
+)
+
+// HTTPOptions configures the requests of the HTTP time sources.
+type HTTPOptions struct {
+	// Method is HTTPMethodAuto (the default when empty), HTTPMethodHead or HTTPMethodGet.
+	Method string
+	// Proxy is the URL of an HTTP(S) proxy, with optional user:password for basic
+	// authentication. When empty HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honored.
+	Proxy string
+	// Headers are added to every request.
+	Headers http.Header
+	// UserAgent replaces DefaultUserAgent when set.
+	UserAgent string
+	// MaxRedirects limits the redirects followed; with 0 a redirect is an error.
+	MaxRedirects int
+	// NoRedirects takes the time from the first response, even when it is a redirect.
+	NoRedirects bool
+}
+
+// DefaultMaxRedirects is the default of --max-redirects.
+const DefaultMaxRedirects = 10
+
+// DefaultUserAgent identifies ntpcl to servers, rather than Go's default user agent that some WAFs block.
+const DefaultUserAgent = "ntpcl"
+
+// ParseHeader parses a "Name: value" header line.
+func ParseHeader(line string) (string, string, error) {
+	name, value, ok := strings.Cut(line, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return "", "", fmt.Errorf("invalid header %q, expected \"Name: value\"", line)
+	}
+	return http.CanonicalHeaderKey(name), strings.TrimSpace(value), nil
+}
+
+// Validate checks the options before any request is made.
+func (o HTTPOptions) Validate() error {
+	if _, err := o.methods(); err != nil {
+		return err
+	}
+	if o.Proxy != "" {
+		if _, err := parseProxyURL(o.Proxy); err != nil {
+			return err
+		}
+	}
+	if o.MaxRedirects < 0 {
+		return fmt.Errorf("invalid redirect limit %d", o.MaxRedirects)
+	}
+	return nil
+}
+
+// methods returns the request methods to try, in order.
+func (o HTTPOptions) methods() ([]string, error) {
+	switch strings.ToUpper(o.Method) {
+	case "", strings.ToUpper(HTTPMethodAuto):
+		return []string{http.MethodHead, http.MethodGet}, nil
+	case HTTPMethodHead:
+		return []string{http.MethodHead}, nil
+	case HTTPMethodGet:
+		return []string{http.MethodGet}, nil
+	default:
+		return nil, fmt.Errorf("unsupported HTTP method %q", o.Method)
+	}
+}
+
+// client returns the HTTP client used for time requests.
+func (o HTTPOptions) client() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = o.proxyFor
+	if SOCKS5Proxy != "" {
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialTCP(ctx, addr)
+		}
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		// Redirects are followed by do, which times each hop.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// do sends req and follows redirects within the configured limit. The round
+// trip time is that of the final response only, so a redirect to a distant
+// node does not inflate it. The returned chain lists the URLs redirected to.
+func (o HTTPOptions) do(client *http.Client, req *http.Request) (*http.Response, time.Duration, []string, error) {
+	var chain []string
+	for {
+		dumpHTTPRequest(req)
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, chain, err
+		}
+		rtt := time.Since(start)
+		dumpHTTPResponse(resp)
+		Logger.Debug("HTTP response", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "rtt", rtt)
+
+		location := resp.Header.Get("Location")
+		if o.NoRedirects || location == "" || resp.StatusCode < 300 || resp.StatusCode > 399 {
+			return resp, rtt, chain, nil
+		}
+		resp.Body.Close()
+		if len(chain) == o.MaxRedirects {
+			return nil, 0, chain, fmt.Errorf("stopped after %d redirects", o.MaxRedirects)
+		}
+
+		next, err := req.URL.Parse(location)
+		if err != nil {
+			return nil, 0, chain, fmt.Errorf("invalid redirect to %q: %v", location, err)
+		}
+		Logger.Info("following redirect", "from", req.URL.String(), "to", next.String(), "status", resp.StatusCode)
+		method := req.Method
+		if resp.StatusCode == http.StatusSeeOther && method != http.MethodHead {
+			method = http.MethodGet
+		}
+		nextReq, err := http.NewRequest(method, next.String(), nil)
+		if err != nil {
+			return nil, 0, chain, err
+		}
+		nextReq.Header = req.Header.Clone()
+		if next.Host != req.URL.Host {
+			// As net/http does, credentials are not passed on to another host.
+			nextReq.Header.Del("Authorization")
+			nextReq.Header.Del("Cookie")
+		}
+		req = nextReq
+		chain = append(chain, next.String())
+	}
+}
+
+// proxyFor returns the proxy for a request: the configured one, or the one from the environment.
+func (o HTTPOptions) proxyFor(req *http.Request) (*url.URL, error) {
+	if o.Proxy != "" {
+		return parseProxyURL(o.Proxy)
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+func parseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	return u, nil
+}
+
+// dialTLSHost opens a TCP connection for a TLS session with addr, through
+// SOCKS5Proxy when set, otherwise tunneling it through the HTTP proxy with
+// CONNECT when one applies.
+func (o HTTPOptions) dialTLSHost(addr string) (net.Conn, error) {
+	if SOCKS5Proxy != "" {
+		return dialTCP(context.Background(), addr)
+	}
+	dialer := &net.Dialer{Timeout: tcpDialTimeout}
+	proxy, err := o.proxyFor(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+	if err != nil {
+		return nil, err
+	}
+	if proxy == nil {
+		return dialer.Dial("tcp", addr)
+	}
+
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), map[string]string{"http": "80", "https": "443"}[proxy.Scheme])
+	}
+	conn, err := dialer.Dial("tcp", proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if proxy.Scheme == "https" {
+		conn = tls.Client(conn, &tls.Config{ServerName: proxy.Hostname()})
+	}
+
+	connect := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxy.User.Username() + ":" + password))
+		connect += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.Write([]byte(connect + "\r\n")); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// The tunnel carries no data until the client speaks, so nothing past the response is buffered.
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %v", proxy.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT: %s", proxy.Host, resp.Status)
+	}
+	return conn, conn.SetDeadline(time.Time{})
+}
+
+// newRequest builds a request with the configured headers that asks caches
+// along the way for a fresh response.
+func (o HTTPOptions) newRequest(method, url string) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Set("Pragma", "no-cache")
+	req.Header.Set("User-Agent", DefaultUserAgent)
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+	for name, values := range o.Headers {
+		req.Header[name] = values
+	}
+	return req, nil
+}
@@ -0,0 +1,104 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultJSONTimeFields are the fields tried, in order, when no field is given:
+// worldtimeapi.org's utc_datetime (preferred as it carries microseconds) and
+// unixtime, and timeapi.io's dateTime.
+var DefaultJSONTimeFields = []string{"utc_datetime", "unixtime", "dateTime"}
+
+// jsonTimeLayouts are the string formats accepted for a time field. Times
+// without a zone are taken as UTC.
+var jsonTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"}
+
+// FetchTimeFromHTTPJSON fetches a JSON document and reads the time from field,
+// a dot-separated path such as "data.unixtime". The field may hold Unix seconds
+// (or milliseconds) or a date string. Half the round trip is added to compensate
+// for the time the response took to arrive. Like FetchTimeFromHTTP it also
+// returns the URLs it was redirected to.
+func FetchTimeFromHTTPJSON(url, field string, httpOpts HTTPOptions) (time.Time, time.Duration, []string, error) {
+	req, err := httpOpts.newRequest(http.MethodGet, url)
+	if err != nil {
+		return time.Time{}, 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, rtt, redirects, err := httpOpts.do(httpOpts.client(), req)
+	if err != nil {
+		return time.Time{}, 0, redirects, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, 0, redirects, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	var body any
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		return time.Time{}, 0, redirects, fmt.Errorf("failed to decode JSON: %v", err)
+	}
+
+	fields := DefaultJSONTimeFields
+	if field != "" {
+		fields = []string{field}
+	}
+	for _, name := range fields {
+		value, ok := lookupJSONField(body, name)
+		if !ok {
+			continue
+		}
+		serverTime, err := parseJSONTime(value)
+		if err != nil {
+			return time.Time{}, 0, redirects, fmt.Errorf("field %s: %v", name, err)
+		}
+		return serverTime.Add(rtt / 2), rtt, redirects, nil
+	}
+	return time.Time{}, 0, redirects, fmt.Errorf("no time field (%s) in response", strings.Join(fields, ", "))
+}
+
+func lookupJSONField(body any, path string) (any, bool) {
+	value := body
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func parseJSONTime(value any) (time.Time, error) {
+	switch v := value.(type) {
+	case json.Number:
+		seconds, err := v.Float64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		// Values this large are milliseconds.
+		if seconds > 1e11 {
+			seconds /= 1000
+		}
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	case string:
+		for _, layout := range jsonTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognized time %q", v)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported value %v", value)
+	}
+}
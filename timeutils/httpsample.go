@@ -0,0 +1,78 @@
+package timeutils
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// minSamplingPrecision stops second-boundary sampling once the offset is known this well.
+const minSamplingPrecision = 5 * time.Millisecond
+
+// SampleHTTPDate estimates the server time to well below the one-second
+// resolution of the Date header, the way htpdate does. Each response bounds the
+// clock offset to a one-second window; every further request is timed so that
+// the server's second ticks over in the middle of the remaining window, halving
+// it. It returns the server time, the smallest round trip seen, the achieved
+// precision (half the remaining window) and the redirects of the first request.
+func SampleHTTPDate(url string, httpOpts HTTPOptions, samples int) (time.Time, time.Duration, time.Duration, []string, error) {
+	methods, err := httpOpts.methods()
+	if err != nil {
+		return time.Time{}, 0, 0, nil, err
+	}
+	client := httpOpts.client()
+
+	// The first request settles the method and the initial window.
+	var lo, hi, minRTT time.Duration
+	var redirects []string
+	var method string
+	for i, candidate := range methods {
+		var err error
+		lo, hi, minRTT, redirects, err = sampleHTTPWindow(httpOpts, client, candidate, url)
+		if err == nil {
+			method = candidate
+			break
+		}
+		if i == len(methods)-1 {
+			return time.Time{}, 0, 0, redirects, err
+		}
+	}
+
+	for i := 1; i < samples && hi-lo > 2*minSamplingPrecision; i++ {
+		// Aim the middle of the request at the instant the server's second
+		// would tick over if the offset were in the middle of the window.
+		mid := (lo + hi) / 2
+		boundary := time.Now().Add(mid + minRTT + 50*time.Millisecond).Truncate(time.Second).Add(time.Second)
+		time.Sleep(time.Until(boundary.Add(-mid - minRTT/2)))
+
+		sampleLo, sampleHi, rtt, _, err := sampleHTTPWindow(httpOpts, client, method, url)
+		if err != nil {
+			return time.Time{}, 0, 0, redirects, err
+		}
+		minRTT = min(minRTT, rtt)
+		if max(lo, sampleLo) >= min(hi, sampleHi) {
+			// Network jitter made the windows inconsistent; keep what is known.
+			break
+		}
+		lo, hi = max(lo, sampleLo), min(hi, sampleHi)
+	}
+
+	offset := (lo + hi) / 2
+	return time.Now().Add(offset), minRTT, (hi - lo) / 2, redirects, nil
+}
+
+// sampleHTTPWindow sends one request and returns the window [lo, hi) its Date
+// header leaves for the clock offset: the server read its clock somewhere
+// between sending the request and receiving the response.
+func sampleHTTPWindow(httpOpts HTTPOptions, client *http.Client, method, url string) (time.Duration, time.Duration, time.Duration, []string, error) {
+	date, rtt, redirects, err := fetchHTTPDate(httpOpts, client, method, url)
+	received := time.Now()
+	if err != nil {
+		return 0, 0, 0, redirects, err
+	}
+	if date.Nanosecond() != 0 {
+		return 0, 0, 0, redirects, fmt.Errorf("unexpected sub-second Date %v", date)
+	}
+	sent := received.Add(-rtt)
+	return date.Sub(received), date.Add(time.Second).Sub(sent), rtt, redirects, nil
+}
@@ -0,0 +1,77 @@
+package timeutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const instanceLockFile = "daemon.lock"
+
+// ErrAlreadyRunning is returned by AcquireInstanceLock while another daemon
+// uses the same state directory.
+var ErrAlreadyRunning = errors.New("another ntpcl daemon is running")
+
+// InstanceLock keeps a second daemon from running on the same state directory
+// and owns the pidfile, if any. The operating system drops the lock when the
+// process exits, so a crash does not leave a stale lock behind.
+type InstanceLock struct {
+	file    *os.File
+	pidFile string
+}
+
+// AcquireInstanceLock locks the state directory and writes the process id to
+// pidFile unless it is empty.
+func AcquireInstanceLock(stateDir, pidFile string) (*InstanceLock, error) {
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(stateDir, instanceLockFile)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(file); err != nil {
+		holder, _ := os.ReadFile(path)
+		file.Close()
+		if pid := strings.TrimSpace(string(holder)); pid != "" {
+			return nil, fmt.Errorf("%w (pid %s, lock %s)", ErrAlreadyRunning, pid, path)
+		}
+		return nil, fmt.Errorf("%w (lock %s)", ErrAlreadyRunning, path)
+	}
+
+	pid := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt(pid, 0)
+	}
+	lock := &InstanceLock{file: file}
+	if pidFile != "" {
+		if err := os.WriteFile(pidFile, pid, 0o644); err != nil {
+			lock.Release()
+			return nil, fmt.Errorf("failed to write the pidfile: %v", err)
+		}
+		lock.pidFile = pidFile
+	}
+	return lock, nil
+}
+
+// Release removes the pidfile and drops the lock.
+func (l *InstanceLock) Release() error {
+	if l == nil {
+		return nil
+	}
+	var err error
+	if l.pidFile != "" {
+		if removeErr := os.Remove(l.pidFile); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			err = removeErr
+		}
+	}
+	l.file.Truncate(0)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package timeutils
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// IP policies: which of the addresses a server name resolves to are queried.
+const (
+	// IPPolicyFirst queries the first address, IPv4 before IPv6.
+	IPPolicyFirst = "first"
+	// IPPolicyRandom queries one address picked at random.
+	IPPolicyRandom = "random"
+	// IPPolicyFastest queries every address and uses the answer with the shortest round trip.
+	IPPolicyFastest = "fastest"
+	// IPPolicyAll queries every address like IPPolicyFastest and reports the result of each.
+	IPPolicyAll = "all"
+)
+
+// IsIPPolicy reports whether policy is a known IP policy.
+func IsIPPolicy(policy string) bool {
+	switch policy {
+	case IPPolicyFirst, IPPolicyRandom, IPPolicyFastest, IPPolicyAll:
+		return true
+	}
+	return false
+}
+
+// SelectAddresses returns the addresses to query under policy, from the
+// addresses as ResolveServer returns them. For first and random, the selected
+// address comes first and the others follow it, to fall back on when it fails.
+func SelectAddresses(addresses []string, policy string) []string {
+	if policy != IPPolicyRandom || len(addresses) <= 1 {
+		return addresses
+	}
+	i := rand.Intn(len(addresses))
+	selected := append([]string{addresses[i]}, addresses[:i]...)
+	return append(selected, addresses[i+1:]...)
+}
+
+// QueriesAllAddresses reports whether policy queries every address, rather
+// than falling back on the next one only when an address fails.
+func QueriesAllAddresses(policy string) bool {
+	return policy == IPPolicyFastest || policy == IPPolicyAll
+}
+
+// AddressResult is the outcome of querying one of the addresses of a server name.
+type AddressResult struct {
+	Address string
+	Offset  time.Duration
+	RTT     time.Duration
+	Stratum uint8
+	Error   string // why the query failed, if it did
+	Used    bool   // whether this answer was the one used
+}
+
+type jsonAddressResult struct {
+	Address string  `json:"address"`
+	Offset  float64 `json:"offset_seconds,omitempty"`
+	RTT     float64 `json:"rtt_seconds,omitempty"`
+	Stratum uint8   `json:"stratum,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Used    bool    `json:"used"`
+}
+
+func (r AddressResult) jsonValue() jsonAddressResult {
+	return jsonAddressResult{
+		Address: r.Address,
+		Offset:  r.Offset.Seconds(),
+		RTT:     r.RTT.Seconds(),
+		Stratum: r.Stratum,
+		Error:   r.Error,
+		Used:    r.Used,
+	}
+}
+
+// FormatAddressResults renders the results of querying each address of name
+// as a table, or as lines with PlainOutput. The answer used is marked with "*".
+func FormatAddressResults(name string, results []AddressResult) string {
+	var buf bytes.Buffer
+	if PlainOutput {
+		for _, r := range results {
+			marker := " "
+			if r.Used {
+				marker = "*"
+			}
+			if r.Error != "" {
+				fmt.Fprintf(&buf, "%s %s: failed: %s\n", marker, r.Address, r.Error)
+				continue
+			}
+			fmt.Fprintf(&buf, "%s %s: offset %v, rtt %v, stratum %d\n", marker, r.Address, r.Offset, r.RTT, r.Stratum)
+		}
+		return buf.String()
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		marker := ""
+		if r.Used {
+			marker = "*"
+		}
+		if r.Error != "" {
+			rows = append(rows, []string{marker, r.Address, "-", "-", "-", r.Error})
+			continue
+		}
+		rows = append(rows, []string{marker, r.Address, r.Offset.String(), r.RTT.String(), strconv.Itoa(int(r.Stratum)), ""})
+	}
+	fmt.Fprintf(&buf, "Addresses of %s:\n", name)
+	renderTable(&buf, []string{"", "Address", "Offset", "RTT", "Stratum", "Error"}, rows, tableStyle{noWrap: true})
+	return buf.String()
+}
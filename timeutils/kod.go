@@ -0,0 +1,147 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Kiss-of-death backoff bounds for RATE: the first backoff is one minimum NTP
+// poll interval and it doubles up to the maximum poll interval.
+const (
+	minKissBackoff = 64 * time.Second
+	maxKissBackoff = 36 * time.Hour
+)
+
+// KissOfDeathError is returned when a server answers with a kiss-of-death.
+type KissOfDeathError struct {
+	Code string
+}
+
+func (e *KissOfDeathError) Error() string {
+	if meaning, ok := kissCodes[e.Code]; ok {
+		return fmt.Sprintf("kiss-of-death response (code %q: %s)", e.Code, meaning)
+	}
+	return fmt.Sprintf("kiss-of-death response (code %q)", e.Code)
+}
+
+const kissStateFile = "kiss-state.json"
+
+// KissBackoff keeps servers that sent a kiss-of-death from being queried again:
+// after RATE for an exponentially growing interval, after DENY or RSTR for
+// maxKissBackoff. Loaded with LoadKissBackoff, the backoff is kept in the
+// state directory, so it holds across the daemon and repeated one-shot runs.
+// A nil *KissBackoff allows every server.
+type KissBackoff struct {
+	mu      sync.Mutex
+	path    string
+	servers map[string]*kissState
+}
+
+type kissState struct {
+	Code   string        `json:"code"`
+	Delay  time.Duration `json:"delay,omitempty"`
+	Until  time.Time     `json:"until"`
+	Denied bool          `json:"denied,omitempty"`
+}
+
+// NewKissBackoff creates an empty backoff tracker that is kept in memory only.
+func NewKissBackoff() *KissBackoff {
+	return &KissBackoff{servers: map[string]*kissState{}}
+}
+
+// LoadKissBackoff reads the backoff from the state directory.
+func LoadKissBackoff(dir string) (*KissBackoff, error) {
+	b := &KissBackoff{path: filepath.Join(dir, kissStateFile), servers: map[string]*kissState{}}
+	data, err := os.ReadFile(b.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &b.servers); err != nil {
+		return nil, fmt.Errorf("corrupt state file: %v", err)
+	}
+	return b, nil
+}
+
+// save writes the backoff to the state directory, if it is kept there.
+func (b *KissBackoff) save() error {
+	if b.path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b.servers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, data, 0o600)
+}
+
+// Check returns an error when server must not be queried yet.
+func (b *KissBackoff) Check(server string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.servers[server]
+	switch {
+	case !ok || !time.Now().Before(state.Until):
+		return nil
+	case state.Denied:
+		return fmt.Errorf("not querying %s until %s after kiss-of-death %s", server, state.Until.Format(time.RFC3339), state.Code)
+	}
+	return fmt.Errorf("backing off from %s until %s after kiss-of-death %s", server, state.Until.Format(time.RFC3339), state.Code)
+}
+
+// Observe records a kiss-of-death from server, saves the backoff and returns
+// how long the server is avoided.
+func (b *KissBackoff) Observe(server, code string) (time.Duration, error) {
+	if b == nil {
+		return 0, nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state, ok := b.servers[server]
+	if !ok {
+		state = &kissState{}
+		b.servers[server] = state
+	}
+	state.Code = code
+
+	switch code {
+	case "DENY", "RSTR":
+		state.Denied = true
+		state.Delay = maxKissBackoff
+	default:
+		// RATE, and codes without a defined reaction, slow down the queries.
+		state.Delay = min(max(2*state.Delay, minKissBackoff), maxKissBackoff)
+	}
+	state.Until = time.Now().Add(state.Delay)
+	Logger.Info("backing off after kiss-of-death", "server", server, "code", code, "delay", state.Delay)
+	return state.Delay, b.save()
+}
+
+// Reset forgets the backoff of server after it answered normally.
+func (b *KissBackoff) Reset(server string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.servers[server]; !ok {
+		return nil
+	}
+	delete(b.servers, server)
+	return b.save()
+}
@@ -0,0 +1,47 @@
+package timeutils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// DescribeLeap describes the leap indicator of an NTP response.
+func DescribeLeap(leap ntp.LeapIndicator) string {
+	switch leap {
+	case ntp.LeapNoWarning:
+		return "0 (no leap second)"
+	case ntp.LeapAddSecond:
+		return "1 (leap second insertion announced)"
+	case ntp.LeapDelSecond:
+		return "2 (leap second deletion announced)"
+	default:
+		return "3 (unsynchronized)"
+	}
+}
+
+// NextLeapSecond returns when an announced leap second takes effect: at the
+// end of the last day of the month of t, in UTC.
+func NextLeapSecond(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// LeapWarning returns a warning when the response announces a leap second,
+// measured at the server time t, or "" otherwise.
+func LeapWarning(response *ntp.Response, t time.Time) string {
+	if response == nil {
+		return ""
+	}
+	at := NextLeapSecond(t)
+	switch response.Leap {
+	case ntp.LeapAddSecond:
+		return fmt.Sprintf("the server announces a leap second: 23:59:60 UTC will be inserted before %s (in %v)",
+			at.Format(time.RFC3339), at.Sub(t).Round(time.Second))
+	case ntp.LeapDelSecond:
+		return fmt.Sprintf("the server announces a leap second: 23:59:59 UTC will be skipped before %s (in %v)",
+			at.Format(time.RFC3339), at.Sub(t).Round(time.Second))
+	}
+	return ""
+}
@@ -0,0 +1,287 @@
+package timeutils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The bundled table is the IERS file, refreshed after each Bulletin C (every
+// January and July) so that it does not expire between releases; the hash
+// check of ParseLeapSecondsList catches a damaged download at start-up.
+//
+//go:generate curl -fsSL -o leap-seconds.list https://hpiers.obspm.fr/iers/bul/bulc/ntp/leap-seconds.list
+//go:embed leap-seconds.list
+var bundledLeapSeconds []byte
+
+// Leap modes: how the daemon takes the clock through a leap second.
+const (
+	// LeapModeStep steps the clock by the leap second when it occurs.
+	LeapModeStep = "step"
+	// LeapModeSmear spreads the leap second over the 24 hours around it.
+	LeapModeSmear = "smear"
+)
+
+// leapGuard is how close to a leap second the clock is not set from a source:
+// servers that do not handle the leap well disagree by a second around it.
+const leapGuard = 2 * time.Minute
+
+// smearWindow is the length of the linear smear, noon to noon UTC around the leap.
+const smearWindow = 24 * time.Hour
+
+// LeapSecond is one entry of the leap second table.
+type LeapSecond struct {
+	At     time.Time // when the new offset applies, i.e. right after the leap second
+	TAIUTC int       // TAI-UTC in seconds from At on
+}
+
+// LeapTable is a parsed leap-seconds.list file.
+type LeapTable struct {
+	Updated time.Time
+	Expires time.Time
+	Leaps   []LeapSecond
+}
+
+// Leaps is the leap second table in use: the bundled one unless replaced with LoadLeapTable.
+var Leaps = mustParseLeapTable(bundledLeapSeconds)
+
+func mustParseLeapTable(data []byte) *LeapTable {
+	table, err := ParseLeapSecondsList(bytes.NewReader(data))
+	if err != nil {
+		panic(fmt.Sprintf("bundled leap-seconds.list: %v", err))
+	}
+	return table
+}
+
+// LoadLeapTable reads a leap-seconds.list file.
+func LoadLeapTable(path string) (*LeapTable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	table, err := ParseLeapSecondsList(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return table, nil
+}
+
+// ParseLeapSecondsList parses the IERS/NIST leap-seconds.list format and
+// verifies its #h hash when there is one.
+func ParseLeapSecondsList(r io.Reader) (*LeapTable, error) {
+	table := &LeapTable{}
+	var hashed strings.Builder
+	var updated, expires, hash string
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(text, "#$"):
+			updated = strings.TrimSpace(text[2:])
+		case strings.HasPrefix(text, "#@"):
+			expires = strings.TrimSpace(text[2:])
+		case strings.HasPrefix(text, "#h"):
+			hash = strings.TrimSpace(text[2:])
+		case text == "", strings.HasPrefix(text, "#"):
+		default:
+			data, _, _ := strings.Cut(text, "#")
+			fields := strings.Fields(data)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: expected NTP time and TAI-UTC", line)
+			}
+			at, err1 := strconv.ParseInt(fields[0], 10, 64)
+			offset, err2 := strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("line %d: invalid entry %q", line, text)
+			}
+			hashed.WriteString(fields[0] + fields[1])
+			table.Leaps = append(table.Leaps, LeapSecond{At: time.Unix(at-ntpEpochOffset, 0).UTC(), TAIUTC: offset})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(table.Leaps) == 0 {
+		return nil, fmt.Errorf("no leap seconds found")
+	}
+
+	for _, field := range []struct {
+		value string
+		dst   *time.Time
+	}{{updated, &table.Updated}, {expires, &table.Expires}} {
+		if field.value == "" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(field.value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", field.value)
+		}
+		*field.dst = time.Unix(seconds-ntpEpochOffset, 0).UTC()
+	}
+
+	if hash != "" {
+		sum := sha1.Sum([]byte(updated + expires + hashed.String()))
+		if !leapHashMatches(hash, sum[:]) {
+			return nil, fmt.Errorf("hash mismatch, the file is corrupt")
+		}
+	}
+	return table, nil
+}
+
+// leapHashMatches compares the #h line, five 32-bit hex words whose leading
+// zeros may be dropped, with a SHA-1 sum.
+func leapHashMatches(hash string, sum []byte) bool {
+	words := strings.Fields(hash)
+	if len(words) != 5 {
+		return false
+	}
+	for i, word := range words {
+		v, err := strconv.ParseUint(word, 16, 32)
+		if err != nil || uint32(v) != uint32(sum[4*i])<<24|uint32(sum[4*i+1])<<16|uint32(sum[4*i+2])<<8|uint32(sum[4*i+3]) {
+			return false
+		}
+	}
+	return true
+}
+
+// TAIOffset returns TAI-UTC at t, or 0 before 1972.
+func (lt *LeapTable) TAIOffset(t time.Time) time.Duration {
+	offset := 0
+	for _, leap := range lt.Leaps {
+		if t.Before(leap.At) {
+			break
+		}
+		offset = leap.TAIUTC
+	}
+	return time.Duration(offset) * time.Second
+}
+
+// Expired reports whether the table may be missing leap seconds announced since it expired.
+func (lt *LeapTable) Expired(now time.Time) bool {
+	return !lt.Expires.IsZero() && now.After(lt.Expires)
+}
+
+// nearestLeap returns the leap second of the table closest to t, and its
+// direction: +1 for an inserted second, -1 for a deleted one.
+func (lt *LeapTable) nearestLeap(t time.Time) (time.Time, int, bool) {
+	var best LeapSecond
+	var delta int
+	for i := 1; i < len(lt.Leaps); i++ {
+		leap := lt.Leaps[i]
+		if delta == 0 || leap.At.Sub(t).Abs() < best.At.Sub(t).Abs() {
+			best, delta = leap, leap.TAIUTC-lt.Leaps[i-1].TAIUTC
+		}
+	}
+	return best.At, delta, delta != 0
+}
+
+// PendingLeap returns the leap second nearest to t, taken from the table or
+// announced in the leap indicator of an NTP response, with its direction. An
+// announcement is only believed while the table agrees with it or after the
+// table expired, since servers that keep the leap bits set past the leap, or
+// set them by mistake, would otherwise step the clock by a second.
+func PendingLeap(t time.Time, leap uint8) (time.Time, int, bool) {
+	var delta int
+	switch leap {
+	case 1:
+		delta = 1
+	case 2:
+		delta = -1
+	}
+	if delta != 0 {
+		at := NextLeapSecond(t)
+		if Leaps.Expired(t) {
+			return at, delta, true
+		}
+		if tableAt, tableDelta, ok := Leaps.nearestLeap(t); ok && tableAt.Equal(at) && tableDelta == delta {
+			return at, delta, true
+		}
+		Logger.Debug("ignoring a leap second announcement the leap second table does not have", "at", at, "delta", delta)
+	}
+	return Leaps.nearestLeap(t)
+}
+
+// NearLeap reports whether t is too close to the leap second at to trust a source.
+func NearLeap(t, at time.Time) bool {
+	return t.Sub(at).Abs() < leapGuard
+}
+
+// LeapSmear returns what a clock smearing the leap second at (in direction
+// delta) shows minus UTC at the UTC time u. The leap second is spread linearly
+// over the 24 hours from noon to noon UTC around it, as Google and AWS do.
+func LeapSmear(u, at time.Time, delta int) time.Duration {
+	leap := time.Duration(delta) * time.Second
+	// c is a continuous timescale that does not repeat or skip the leap second.
+	c := u
+	if !u.Before(at) {
+		c = u.Add(leap)
+	}
+	start := at.Add(-smearWindow / 2)
+	fraction := float64(c.Sub(start)) / float64(smearWindow)
+	fraction = max(0, min(1, fraction))
+	smeared := c.Add(-time.Duration(fraction * float64(leap)))
+	return smeared.Sub(u)
+}
+
+// SmearTime returns the UTC time t as a clock smearing the nearest leap
+// second (from the table, or announced by the leap indicator) shows it.
+func SmearTime(t time.Time, leap uint8) time.Time {
+	at, delta, ok := PendingLeap(t, leap)
+	if !ok {
+		return t
+	}
+	return t.Add(LeapSmear(t, at, delta))
+}
+
+// smearingServers are public servers known to smear leap seconds instead of
+// announcing them.
+var smearingServers = map[string]bool{
+	"time.google.com":  true,
+	"time1.google.com": true,
+	"time2.google.com": true,
+	"time3.google.com": true,
+	"time4.google.com": true,
+	"time.aws.com":     true,
+	"216.239.35.0":     true, // time.google.com
+	"216.239.35.4":     true,
+	"216.239.35.8":     true,
+	"216.239.35.12":    true,
+	"169.254.169.123":  true, // Amazon Time Sync Service
+	"fd00:ec2::123":    true,
+}
+
+// DetectSmear reports whether a measurement taken during the 24 hours around a
+// leap second comes from a server that smears it, and if so how far the smear
+// shifts the server's time from UTC. A server is taken to smear when it is a
+// known smearing server or its offset is closer to the smear than to zero.
+func DetectSmear(server string, serverTime time.Time, offset, uncertainty time.Duration, leap uint8) (time.Duration, bool) {
+	at, delta, ok := PendingLeap(serverTime, leap)
+	if !ok || serverTime.Sub(at).Abs() >= smearWindow/2 {
+		return 0, false
+	}
+	smear := LeapSmear(serverTime, at, delta)
+	if smear == 0 {
+		return 0, false
+	}
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+	if smearingServers[strings.ToLower(host)] {
+		return smear, true
+	}
+	if smear.Abs() > 2*uncertainty && (offset-smear).Abs() < offset.Abs() {
+		return smear, true
+	}
+	return 0, false
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+// +build !windows
+
+package timeutils
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an exclusive fcntl lock on the whole file without waiting.
+func lockFile(file *os.File) error {
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+	return unix.FcntlFlock(file.Fd(), unix.F_SETLK, &lock)
+}
+
+// waitLockFile takes an exclusive fcntl lock on the whole file, waiting for it.
+func waitLockFile(file *os.File) error {
+	lock := unix.Flock_t{Type: unix.F_WRLCK, Whence: io.SeekStart}
+	return unix.FcntlFlock(file.Fd(), unix.F_SETLKW, &lock)
+}
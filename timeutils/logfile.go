@@ -0,0 +1,127 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// LogRecord is a single structured query result appended to a log file.
+type LogRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Method    string    `json:"method"`
+	Server    string    `json:"server"`
+	Offset    float64   `json:"offset_seconds"`
+	RTT       float64   `json:"rtt_seconds"`
+	Stratum   int       `json:"stratum"`
+	ClockSet  bool      `json:"clock_set"`
+}
+
+var logRecordColumns = []string{"timestamp", "method", "server", "offset_seconds", "rtt_seconds", "stratum", "clock_set"}
+
+// NewLogRecord builds a log record from the result of a time query. The offset
+// is always taken from serverTime, which may have been combined or refined
+// after the NTP response was received.
+func NewLogRecord(method, server string, serverTime time.Time, rtt time.Duration, ntpResponse *ntp.Response) LogRecord {
+	now := Now()
+	offset := serverTime.Sub(now)
+	stratum := 0
+	if ntpResponse != nil {
+		stratum = int(ntpResponse.Stratum)
+	}
+
+	return LogRecord{
+		Timestamp: now.UTC(),
+		Method:    method,
+		Server:    server,
+		Offset:    offset.Seconds(),
+		RTT:       rtt.Seconds(),
+		Stratum:   stratum,
+	}
+}
+
+// ReportRecord builds the log record of a measurement, with the offset and
+// local time of the report.
+func ReportRecord(report Report) LogRecord {
+	record := NewLogRecord(report.Method, report.Server, report.ServerTime, report.RTT, report.NTPResponse())
+	record.Timestamp = report.LocalTime.UTC()
+	record.Offset = report.TimeDifference().Seconds()
+	record.ClockSet = report.ClockSet
+	return record
+}
+
+// AppendLogRecord appends a record to the given file, creating it if needed,
+// after rotating the file as configured in LogRotate. Files ending in .csv are
+// written as CSV, everything else as NDJSON.
+func AppendLogRecord(path string, record LogRecord) error {
+	csvFile := strings.EqualFold(filepath.Ext(path), ".csv")
+	var line []byte
+	var err error
+	if csvFile {
+		line, err = csvLine(csvRow(record))
+	} else {
+		line, err = json.Marshal(record)
+		line = append(line, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	if err := LogRotate.rotate(path, int64(len(line)), record.Timestamp); err != nil {
+		return fmt.Errorf("failed to rotate %s: %v", path, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if csvFile {
+		// New CSV files start with a header row.
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if info.Size() == 0 {
+			header, err := csvLine(logRecordColumns)
+			if err != nil {
+				return err
+			}
+			line = append(header, line...)
+		}
+	}
+	_, err = file.Write(line)
+	return err
+}
+
+// csvRow returns the fields of the record in the order of logRecordColumns.
+func csvRow(record LogRecord) []string {
+	return []string{
+		record.Timestamp.Format(time.RFC3339Nano),
+		record.Method,
+		record.Server,
+		strconv.FormatFloat(record.Offset, 'f', -1, 64),
+		strconv.FormatFloat(record.RTT, 'f', -1, 64),
+		strconv.Itoa(record.Stratum),
+		strconv.FormatBool(record.ClockSet),
+	}
+}
+
+// csvLine encodes one CSV row.
+func csvLine(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(fields); err != nil {
+		return nil, err
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
@@ -0,0 +1,71 @@
+package timeutils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// LevelTrace is the most verbose level, for per-packet and per-socket detail.
+const LevelTrace = slog.LevelDebug - 4
+
+// Log formats accepted by NewLogger.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Logger receives the diagnostic output of the package. It discards
+// everything until it is replaced, e.g. with NewLogger.
+var Logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+
+// NewLogger creates a logger writing to w in the given format. The verbosity
+// is the number of -v flags: 0 logs warnings only, 1 adds the steps of a query,
+// 2 the debug detail and 3 or more the trace of every socket operation.
+func NewLogger(w io.Writer, format string, verbosity int) (*slog.Logger, error) {
+	handler, err := newLogHandler(w, format, verbosity)
+	if err != nil {
+		return nil, err
+	}
+	return slog.New(handler), nil
+}
+
+// newLogHandler creates the handler of NewLogger.
+func newLogHandler(w io.Writer, format string, verbosity int) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{
+		Level: verbosityLevel(verbosity),
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any().(slog.Level) == LevelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	}
+	switch format {
+	case "", LogFormatText:
+		return slog.NewTextHandler(w, opts), nil
+	case LogFormatJSON:
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (use %s or %s)", format, LogFormatText, LogFormatJSON)
+	}
+}
+
+// verbosityLevel returns the lowest level logged at a verbosity.
+func verbosityLevel(verbosity int) slog.Level {
+	switch {
+	case verbosity >= 3:
+		return LevelTrace
+	case verbosity == 2:
+		return slog.LevelDebug
+	case verbosity == 1:
+		return slog.LevelInfo
+	}
+	return slog.LevelWarn
+}
+
+// logTrace logs at LevelTrace.
+func logTrace(msg string, args ...any) {
+	Logger.Log(context.Background(), LevelTrace, msg, args...)
+}
@@ -0,0 +1,125 @@
+package timeutils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogRotation limits the size and age of the --log-file. The file is renamed
+// to .1 (and older ones to .2 and so on) before the record that would exceed a
+// limit is written, and only Keep rotated files are kept.
+type LogRotation struct {
+	MaxSize int64         // 0 disables size-based rotation
+	MaxAge  time.Duration // age of the first record; 0 disables age-based rotation
+	Keep    int
+}
+
+// LogRotate is the rotation of the files written by AppendLogRecord.
+var LogRotate LogRotation
+
+// ParseByteSize parses a size such as "512K", "10M" or "1G" (powers of 1024);
+// a plain number is in bytes.
+func ParseByteSize(s string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		}
+		if multiplier != 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q: use bytes or a K, M or G suffix", s)
+	}
+	return n * multiplier, nil
+}
+
+// rotate renames path out of the way when writing size more bytes would take
+// it past MaxSize, or when its first record is older than MaxAge.
+func (r LogRotation) rotate(path string, size int64, now time.Time) error {
+	if r.MaxSize <= 0 && r.MaxAge <= 0 {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+	due := r.MaxSize > 0 && info.Size()+size > r.MaxSize
+	if !due && r.MaxAge > 0 {
+		if first, ok := firstRecordTime(path); ok && now.Sub(first) >= r.MaxAge {
+			due = true
+		}
+	}
+	if !due {
+		return nil
+	}
+
+	if err := os.Remove(rotatedName(path, r.Keep)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := r.Keep - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedName(path, i), rotatedName(path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	Logger.Info("rotated log file", "file", path, "size", info.Size(), "keep", r.Keep)
+	if r.Keep < 1 {
+		return os.Remove(path)
+	}
+	return os.Rename(path, rotatedName(path, 1))
+}
+
+// rotatedName is the name of the n-th rotated file, as logrotate names them.
+func rotatedName(path string, n int) string {
+	return path + "." + strconv.Itoa(n)
+}
+
+// firstRecordTime returns the timestamp of the first record of a log file:
+// the first line of an NDJSON file, the row after the header of a CSV file.
+func firstRecordTime(path string) (time.Time, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	csvFile := strings.EqualFold(filepath.Ext(path), ".csv")
+	if csvFile && !scanner.Scan() {
+		return time.Time{}, false
+	}
+	if !scanner.Scan() {
+		return time.Time{}, false
+	}
+	if csvFile {
+		field, _, _ := strings.Cut(scanner.Text(), ",")
+		t, err := time.Parse(time.RFC3339Nano, field)
+		return t, err == nil
+	}
+	var record LogRecord
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.Timestamp.IsZero() {
+		return time.Time{}, false
+	}
+	return record.Timestamp, true
+}
@@ -0,0 +1,120 @@
+package timeutils
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log targets accepted by ParseLogTarget.
+const (
+	// LogTargetAuto is the journal when stderr is connected to it, as under
+	// systemd, and stderr otherwise.
+	LogTargetAuto    = "auto"
+	LogTargetStderr  = "stderr"
+	LogTargetSyslog  = "syslog"
+	LogTargetJournal = "journald"
+	// LogTargetEventLog is the Windows Application event log.
+	LogTargetEventLog = "eventlog"
+)
+
+// syslogFacilities are the syslog facility names and their codes.
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// LogTarget is where the messages of the daemon and the -v output go.
+type LogTarget struct {
+	Kind string
+	// Facility is the syslog facility, "daemon" unless given.
+	Facility string
+}
+
+// ParseLogTarget parses "auto", "stderr", "journald", "eventlog" or "syslog[:facility]".
+func ParseLogTarget(spec string) (LogTarget, error) {
+	kind, facility, hasFacility := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	switch kind {
+	case "", LogTargetAuto, LogTargetStderr, LogTargetJournal, LogTargetEventLog:
+		if hasFacility {
+			return LogTarget{}, fmt.Errorf("%s takes no facility", kind)
+		}
+		if kind == LogTargetEventLog {
+			return LogTarget{Kind: LogTargetEventLog}, nil
+		}
+		if kind == LogTargetJournal || (kind != LogTargetStderr && stderrIsJournal()) {
+			return LogTarget{Kind: LogTargetJournal}, nil
+		}
+		return LogTarget{Kind: LogTargetStderr}, nil
+	case LogTargetSyslog:
+		if !hasFacility {
+			facility = "daemon"
+		}
+		if _, ok := syslogFacilities[facility]; !ok {
+			return LogTarget{}, fmt.Errorf("unknown syslog facility %q", facility)
+		}
+		return LogTarget{Kind: LogTargetSyslog, Facility: facility}, nil
+	default:
+		return LogTarget{}, fmt.Errorf("unknown log target %q (use auto, stderr, journald, eventlog or syslog[:facility])", spec)
+	}
+}
+
+// LogSink is an opened log target. Logger takes the -v output and Writer the
+// messages of the standard logger, one per write.
+type LogSink struct {
+	Logger *slog.Logger
+	Writer io.Writer
+	// Timestamped reports whether the target adds timestamps itself.
+	Timestamped bool
+	// Events records queries and clock changes as structured events, when the target keeps fields.
+	Events EventSink
+}
+
+// EventSink records the outcome of each query and each clock change as a
+// structured event.
+type EventSink interface {
+	SyncResult(report Report) error
+	SyncFailure(source string, err error) error
+	ClockChanged(entry AuditEntry) error
+}
+
+// Open opens the target for -v output in the given format and verbosity. The
+// journal keeps the attributes as fields and ignores the format.
+func (t LogTarget) Open(format string, verbosity int) (*LogSink, error) {
+	switch t.Kind {
+	case LogTargetSyslog:
+		return openSyslogSink(t.Facility, format, verbosity)
+	case LogTargetJournal:
+		return openJournalSink(verbosity)
+	case LogTargetEventLog:
+		return openEventLogSink(format, verbosity)
+	}
+	logger, err := NewLogger(os.Stderr, format, verbosity)
+	if err != nil {
+		return nil, err
+	}
+	return &LogSink{Logger: logger, Writer: os.Stderr}, nil
+}
+
+// errorPrefixes start the messages of the standard logger that report errors,
+// whether written as messages or as logged error values.
+var errorPrefixes = []string{"failed", "cannot", "refusing", "invalid", "unknown"}
+
+// messageLevel infers the severity of a message of the standard logger from
+// its wording: warnings start with "Warning:", errors with one of errorPrefixes.
+func messageLevel(msg string) slog.Level {
+	if strings.HasPrefix(msg, "Warning:") {
+		return slog.LevelWarn
+	}
+	lower := strings.ToLower(msg)
+	for _, prefix := range errorPrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return slog.LevelError
+		}
+	}
+	return slog.LevelInfo
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package timeutils
+
+import "fmt"
+
+// openEventLogSink is only available on Windows.
+func openEventLogSink(_ string, _ int) (*LogSink, error) {
+	return nil, fmt.Errorf("the event log is only available on Windows")
+}
@@ -0,0 +1,170 @@
+//go:build windows
+// +build windows
+
+package timeutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// eventSource is the event source ntpcl writes to the Application log as.
+const eventSource = "ntpcl"
+
+// Event IDs in the Application log. EventCreate.exe, the message file of the
+// source, only takes IDs from 1 to 1000.
+const (
+	eventIDMessage      = 1   // messages and the -v output
+	eventIDSyncResult   = 100 // a successful query
+	eventIDSyncFailure  = 101 // a failed query
+	eventIDClockChanged = 102 // a clock change
+)
+
+// openEventLogSink registers the event source on first use and opens it.
+func openEventLogSink(format string, verbosity int) (*LogSink, error) {
+	if err := registerEventSource(); err != nil {
+		return nil, err
+	}
+	elog, err := eventlog.Open(eventSource)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	inner, err := newLogHandler(buf, format, verbosity)
+	if err != nil {
+		elog.Close()
+		return nil, err
+	}
+	events := &eventLog{log: elog}
+	return &LogSink{
+		Logger:      slog.New(&eventLogHandler{events: events, mu: &sync.Mutex{}, buf: buf, inner: inner}),
+		Writer:      eventLogLineWriter{events},
+		Timestamped: true,
+		Events:      events,
+	}, nil
+}
+
+// registerEventSource adds the ntpcl source with EventCreate.exe as its
+// message file, which needs administrator rights once.
+func registerEventSource() error {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\EventLog\Application\`+eventSource, registry.QUERY_VALUE)
+	if err == nil {
+		key.Close()
+		return nil
+	}
+	if !errors.Is(err, registry.ErrNotExist) {
+		return err
+	}
+	if err := eventlog.InstallAsEventCreate(eventSource, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		return fmt.Errorf("failed to register the %s event source (run once as administrator): %v", eventSource, err)
+	}
+	return nil
+}
+
+// eventLog writes to the Application log.
+type eventLog struct {
+	log *eventlog.Log
+}
+
+// write reports msg with the event type of level.
+func (e *eventLog) write(level slog.Level, id uint32, msg string) error {
+	switch {
+	case level >= slog.LevelError:
+		return e.log.Error(id, msg)
+	case level >= slog.LevelWarn:
+		return e.log.Warning(id, msg)
+	default:
+		return e.log.Info(id, msg)
+	}
+}
+
+// eventFields appends "Name: value" lines to a message, for SIEM parsers.
+func eventFields(msg string, fields ...[2]string) string {
+	var b strings.Builder
+	b.WriteString(msg + "\r\n")
+	for _, field := range fields {
+		b.WriteString("\r\n" + field[0] + ": " + field[1])
+	}
+	return b.String()
+}
+
+func (e *eventLog) SyncResult(report Report) error {
+	fields := [][2]string{
+		{"Server", report.Server},
+		{"Method", report.Method},
+		{"Offset", fmt.Sprintf("%g s", report.TimeDifference().Seconds())},
+		{"RTT", fmt.Sprintf("%g s", report.RTT.Seconds())},
+		{"Uncertainty", fmt.Sprintf("%g s", report.Uncertainty().Seconds())},
+		{"Clock Set", fmt.Sprintf("%t", report.ClockSet)},
+	}
+	if resp := report.NTPResponse(); resp != nil {
+		fields = append(fields, [2]string{"Stratum", fmt.Sprintf("%d", resp.Stratum)})
+	}
+	return e.write(slog.LevelInfo, eventIDSyncResult, eventFields(report.Summary(), fields...))
+}
+
+func (e *eventLog) SyncFailure(source string, err error) error {
+	return e.write(slog.LevelError, eventIDSyncFailure, eventFields(fmt.Sprintf("Query of %s failed.", source),
+		[2]string{"Server", source},
+		[2]string{"Error", err.Error()}))
+}
+
+func (e *eventLog) ClockChanged(entry AuditEntry) error {
+	return e.write(slog.LevelInfo, eventIDClockChanged, eventFields(fmt.Sprintf("The clock was stepped by %v.", entry.Delta),
+		[2]string{"Server", entry.Server},
+		[2]string{"Method", entry.Method},
+		[2]string{"Offset", fmt.Sprintf("%g s", entry.Delta.Seconds())},
+		[2]string{"Old Time", entry.OldTime.Format(time.RFC3339Nano)},
+		[2]string{"New Time", entry.NewTime.Format(time.RFC3339Nano)},
+		[2]string{"User", entry.User}))
+}
+
+// eventLogLineWriter reports each message of the standard logger.
+type eventLogLineWriter struct {
+	events *eventLog
+}
+
+func (w eventLogLineWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	return len(p), w.events.write(messageLevel(msg), eventIDMessage, msg)
+}
+
+// eventLogHandler formats records with the handler of NewLogger and reports
+// them with the event type of their level.
+type eventLogHandler struct {
+	events *eventLog
+	mu     *sync.Mutex
+	buf    *bytes.Buffer // the output of inner, shared with the derived handlers
+	inner  slog.Handler
+}
+
+func (h *eventLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *eventLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf.Reset()
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	return h.events.write(r.Level, eventIDMessage, strings.TrimSpace(h.buf.String()))
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{events: h.events, mu: h.mu, buf: h.buf, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	return &eventLogHandler{events: h.events, mu: h.mu, buf: h.buf, inner: h.inner.WithGroup(name)}
+}
@@ -0,0 +1,229 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// Journal MESSAGE_IDs of the events, for journalctl MESSAGE_ID=... queries.
+const (
+	journalSyncResultID   = "9bd68b3080144fb68ac627cbe9002209"
+	journalSyncFailureID  = "77f777a8663b4e90ada8f66e540e0b83"
+	journalClockChangedID = "c481bbac446f411a97c9b707d9e2f7a9"
+)
+
+// Journal priorities, which are syslog severities.
+const (
+	journalErr     = 3
+	journalWarning = 4
+	journalNotice  = 5
+	journalInfo    = 6
+	journalDebug   = 7
+)
+
+// stderrIsJournal reports whether systemd connected stderr to the journal:
+// JOURNAL_STREAM then holds the device and inode of stderr.
+func stderrIsJournal() bool {
+	stream := os.Getenv("JOURNAL_STREAM")
+	if stream == "" {
+		return false
+	}
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stream == fmt.Sprintf("%d:%d", stat.Dev, stat.Ino)
+}
+
+// journal writes entries to journald in its native protocol.
+type journal struct {
+	conn *net.UnixConn
+}
+
+// openJournalSink connects to the journald socket.
+func openJournalSink(verbosity int) (*LogSink, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	j := &journal{conn: conn}
+	return &LogSink{
+		Logger:      slog.New(&journalHandler{journal: j, level: verbosityLevel(verbosity)}),
+		Writer:      journalLineWriter{j},
+		Timestamped: true,
+		Events:      j,
+	}, nil
+}
+
+// send writes one entry. Values spanning lines use the binary field format.
+func (j *journal) send(priority int, message string, fields ...[2]string) error {
+	var buf bytes.Buffer
+	write := func(key, value string) {
+		if !strings.Contains(value, "\n") {
+			buf.WriteString(key + "=" + value + "\n")
+			return
+		}
+		buf.WriteString(key + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value + "\n")
+	}
+	write("MESSAGE", message)
+	write("PRIORITY", strconv.Itoa(priority))
+	write("SYSLOG_IDENTIFIER", "ntpcl")
+	for _, field := range fields {
+		write(field[0], field[1])
+	}
+	_, err := j.conn.Write(buf.Bytes())
+	return err
+}
+
+// journalSeconds formats a duration as seconds for OFFSET and the like.
+func journalSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+func (j *journal) SyncResult(report Report) error {
+	fields := [][2]string{
+		{"MESSAGE_ID", journalSyncResultID},
+		{"SERVER", report.Server},
+		{"METHOD", report.Method},
+		{"OFFSET", journalSeconds(report.TimeDifference())},
+		{"RTT", journalSeconds(report.RTT)},
+		{"UNCERTAINTY", journalSeconds(report.Uncertainty())},
+		{"CLOCK_SET", strconv.FormatBool(report.ClockSet)},
+	}
+	if resp := report.NTPResponse(); resp != nil {
+		fields = append(fields, [2]string{"STRATUM", strconv.Itoa(int(resp.Stratum))})
+	}
+	return j.send(journalInfo, report.Summary(), fields...)
+}
+
+func (j *journal) SyncFailure(source string, err error) error {
+	return j.send(journalErr, fmt.Sprintf("query of %s failed: %v", source, err),
+		[2]string{"MESSAGE_ID", journalSyncFailureID},
+		[2]string{"SERVER", source},
+		[2]string{"ERROR", err.Error()})
+}
+
+func (j *journal) ClockChanged(entry AuditEntry) error {
+	return j.send(journalNotice, entry.String(),
+		[2]string{"MESSAGE_ID", journalClockChangedID},
+		[2]string{"SERVER", entry.Server},
+		[2]string{"METHOD", entry.Method},
+		[2]string{"OFFSET", journalSeconds(entry.Delta)},
+		[2]string{"OLD_TIME", entry.OldTime.Format(time.RFC3339Nano)},
+		[2]string{"NEW_TIME", entry.NewTime.Format(time.RFC3339Nano)},
+		[2]string{"CHANGED_BY", entry.User})
+}
+
+// journalPriority maps a log level to a journal priority.
+func journalPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return journalErr
+	case level >= slog.LevelWarn:
+		return journalWarning
+	case level >= slog.LevelInfo:
+		return journalInfo
+	default:
+		return journalDebug
+	}
+}
+
+// journalLineWriter sends each message of the standard logger to the journal.
+type journalLineWriter struct {
+	journal *journal
+}
+
+func (w journalLineWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	return len(p), w.journal.send(journalPriority(messageLevel(msg)), msg)
+}
+
+// journalHandler sends records to the journal with their attributes as
+// fields, e.g. server=... as SERVER=...; groups prefix the field names.
+type journalHandler struct {
+	journal *journal
+	level   slog.Level
+	fields  [][2]string
+	prefix  string
+}
+
+func (h *journalHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *journalHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := append([][2]string(nil), h.fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendJournalField(fields, h.prefix, a)
+		return true
+	})
+	return h.journal.send(journalPriority(r.Level), r.Message, fields...)
+}
+
+func (h *journalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.fields = append([][2]string(nil), h.fields...)
+	for _, a := range attrs {
+		next.fields = appendJournalField(next.fields, h.prefix, a)
+	}
+	return &next
+}
+
+func (h *journalHandler) WithGroup(name string) slog.Handler {
+	next := *h
+	next.prefix = h.prefix + name + "_"
+	return &next
+}
+
+// appendJournalField appends an attribute, flattening groups.
+func appendJournalField(fields [][2]string, prefix string, a slog.Attr) [][2]string {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		for _, member := range value.Group() {
+			fields = appendJournalField(fields, prefix+a.Key+"_", member)
+		}
+		return fields
+	}
+	name := journalFieldName(prefix + a.Key)
+	if name == "" {
+		return fields
+	}
+	return append(fields, [2]string{name, value.String()})
+}
+
+// journalFieldName turns an attribute key into a journal field name:
+// uppercase letters, digits and underscores, not starting with an underscore,
+// which journald reserves for its trusted fields.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+	name = strings.TrimLeft(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "F_" + name
+	}
+	return name
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package timeutils
+
+import "fmt"
+
+// stderrIsJournal is always false: journald only exists on Linux.
+func stderrIsJournal() bool {
+	return false
+}
+
+// openJournalSink is only available on Linux.
+func openJournalSink(_ int) (*LogSink, error) {
+	return nil, fmt.Errorf("journald is only available on Linux")
+}
@@ -0,0 +1,87 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package timeutils
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"log/syslog"
+	"strings"
+	"sync"
+)
+
+// openSyslogSink connects to the local syslog daemon under the "ntpcl" tag.
+func openSyslogSink(facility, format string, verbosity int) (*LogSink, error) {
+	writer, err := syslog.New(syslog.Priority(syslogFacilities[facility]<<3)|syslog.LOG_INFO, "ntpcl")
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	inner, err := newLogHandler(buf, format, verbosity)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+	return &LogSink{
+		Logger:      slog.New(&syslogHandler{writer: writer, mu: &sync.Mutex{}, buf: buf, inner: inner}),
+		Writer:      syslogLineWriter{writer},
+		Timestamped: true,
+	}, nil
+}
+
+// writeSyslog sends msg with the syslog severity of level.
+func writeSyslog(w *syslog.Writer, level slog.Level, msg string) error {
+	switch {
+	case level >= slog.LevelError:
+		return w.Err(msg)
+	case level >= slog.LevelWarn:
+		return w.Warning(msg)
+	case level >= slog.LevelInfo:
+		return w.Info(msg)
+	default:
+		return w.Debug(msg)
+	}
+}
+
+// syslogLineWriter sends each message of the standard logger to syslog.
+type syslogLineWriter struct {
+	writer *syslog.Writer
+}
+
+func (s syslogLineWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	return len(p), writeSyslog(s.writer, messageLevel(msg), msg)
+}
+
+// syslogHandler formats records with the handler of NewLogger and sends them
+// to syslog with the severity of their level.
+type syslogHandler struct {
+	writer *syslog.Writer
+	mu     *sync.Mutex
+	buf    *bytes.Buffer // the output of inner, shared with the derived handlers
+	inner  slog.Handler
+}
+
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buf.Reset()
+	if err := h.inner.Handle(ctx, r); err != nil {
+		return err
+	}
+	return writeSyslog(h.writer, r.Level, strings.TrimSpace(h.buf.String()))
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{writer: h.writer, mu: h.mu, buf: h.buf, inner: h.inner.WithAttrs(attrs)}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{writer: h.writer, mu: h.mu, buf: h.buf, inner: h.inner.WithGroup(name)}
+}
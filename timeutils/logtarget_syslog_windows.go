@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package timeutils
+
+import "fmt"
+
+// openSyslogSink is not available on Windows.
+func openSyslogSink(_, _ string, _ int) (*LogSink, error) {
+	return nil, fmt.Errorf("syslog is not available on Windows")
+}
@@ -0,0 +1,248 @@
+package timeutils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket presets accepted by ParseBuckets.
+var bucketPresets = map[string][]time.Duration{
+	"lan": {
+		10 * time.Microsecond, 25 * time.Microsecond, 50 * time.Microsecond, 100 * time.Microsecond,
+		250 * time.Microsecond, 500 * time.Microsecond, time.Millisecond, 2500 * time.Microsecond,
+		5 * time.Millisecond, 10 * time.Millisecond,
+	},
+	"wan": {
+		time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond,
+		50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+		time.Second,
+	},
+}
+
+// DefaultBuckets is the bucket preset used when none is configured.
+const DefaultBuckets = "wan"
+
+// Metrics collects clock health values and exposes them in the Prometheus text format.
+type Metrics struct {
+	mu             sync.Mutex
+	offset         float64
+	rtt            float64
+	stratum        float64
+	rootDispersion float64
+	lastSync       time.Time
+	failures       uint64
+	kissOfDeath    uint64
+	falsetickers   []string
+	holdover       *HoldoverStatus
+	offsetHist     *histogram
+	rttHist        *histogram
+}
+
+// histogram is a cumulative Prometheus histogram over durations in seconds.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(buckets []time.Duration) *histogram {
+	bounds := make([]float64, len(buckets))
+	for i, b := range buckets {
+		bounds[i] = b.Seconds()
+	}
+	sort.Float64s(bounds)
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) writeTo(w io.Writer, name, help string) (int64, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(&b, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n%s_sum %g\n%s_count %d\n", name, h.count, name, h.sum, name, h.count)
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ParseBuckets parses a bucket specification: either a preset name ("lan" for
+// microsecond-grade, "wan" for millisecond-grade) or a comma-separated list of durations.
+func ParseBuckets(spec string) ([]time.Duration, error) {
+	if spec == "" {
+		spec = DefaultBuckets
+	}
+	if preset, ok := bucketPresets[strings.ToLower(spec)]; ok {
+		return preset, nil
+	}
+
+	var buckets []time.Duration
+	for _, field := range strings.Split(spec, ",") {
+		d, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %v", field, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("bucket %q must be positive", field)
+		}
+		buckets = append(buckets, d)
+	}
+	return buckets, nil
+}
+
+// NewMetrics creates an empty metrics collector with the given histogram buckets
+// for the absolute clock offset and the round trip time.
+func NewMetrics(offsetBuckets, rttBuckets []time.Duration) *Metrics {
+	return &Metrics{
+		offsetHist: newHistogram(offsetBuckets),
+		rttHist:    newHistogram(rttBuckets),
+	}
+}
+
+// Observe records a successful measurement.
+func (m *Metrics) Observe(report Report) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if response := report.NTPResponse(); response != nil {
+		if response.IsKissOfDeath() {
+			m.kissOfDeath++
+			return
+		}
+		m.stratum = float64(response.Stratum)
+		m.rootDispersion = response.RootDispersion.Seconds()
+	}
+
+	m.falsetickers = report.Falsetickers
+	m.offset = report.TimeDifference().Seconds()
+	m.rtt = report.RTT.Seconds()
+	m.lastSync = report.LocalTime
+	m.offsetHist.observe(report.TimeDifference().Abs().Seconds())
+	m.rttHist.observe(m.rtt)
+}
+
+// ObserveFailure records a failed measurement.
+func (m *Metrics) ObserveFailure() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures++
+}
+
+// ObserveKissOfDeath records a measurement that failed with a kiss-of-death.
+func (m *Metrics) ObserveKissOfDeath() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures++
+	m.kissOfDeath++
+}
+
+// ObserveHoldover records the holdover status, nil when synchronized.
+func (m *Metrics) ObserveHoldover(holdover *HoldoverStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.holdover = holdover
+}
+
+// ServeHTTP writes the current metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the current metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var lastSync float64
+	if !m.lastSync.IsZero() {
+		lastSync = float64(m.lastSync.UnixNano()) / 1e9
+	}
+
+	var holdover, holdoverError float64
+	if m.holdover != nil {
+		holdover, holdoverError = 1, m.holdover.EstimatedError
+	}
+
+	var written int64
+	write := func(name, kind, help string, value float64) error {
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+		written += int64(n)
+		return err
+	}
+
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"ntpcl_clock_offset_seconds", "gauge", "Offset of the server clock relative to the local clock.", m.offset},
+		{"ntpcl_rtt_seconds", "gauge", "Round trip time of the last query.", m.rtt},
+		{"ntpcl_stratum", "gauge", "Stratum reported by the NTP server.", m.stratum},
+		{"ntpcl_root_dispersion_seconds", "gauge", "Root dispersion reported by the NTP server.", m.rootDispersion},
+		{"ntpcl_last_sync_timestamp_seconds", "gauge", "Unix time of the last successful query.", lastSync},
+		{"ntpcl_sync_failures_total", "counter", "Number of failed queries.", float64(m.failures)},
+		{"ntpcl_kiss_of_death_total", "counter", "Number of kiss-of-death responses received.", float64(m.kissOfDeath)},
+		{"ntpcl_falsetickers", "gauge", "Number of servers left out of the combined offset of the last query as falsetickers.", float64(len(m.falsetickers))},
+		{"ntpcl_holdover", "gauge", "1 while every source fails and the clock is held over.", holdover},
+		{"ntpcl_holdover_estimated_error_seconds", "gauge", "Estimated error accumulated in holdover.", holdoverError},
+	}
+	for _, metric := range metrics {
+		if err := write(metric.name, metric.kind, metric.help, metric.value); err != nil {
+			return written, err
+		}
+	}
+
+	if len(m.falsetickers) > 0 {
+		var b strings.Builder
+		b.WriteString("# HELP ntpcl_falseticker Servers left out of the combined offset of the last query as falsetickers.\n# TYPE ntpcl_falseticker gauge\n")
+		for _, server := range m.falsetickers {
+			fmt.Fprintf(&b, "ntpcl_falseticker{server=%q} 1\n", server)
+		}
+		n, err := io.WriteString(w, b.String())
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	histograms := []struct {
+		name, help string
+		hist       *histogram
+	}{
+		{"ntpcl_clock_offset_abs_seconds", "Distribution of the absolute clock offset.", m.offsetHist},
+		{"ntpcl_rtt_distribution_seconds", "Distribution of the query round trip time.", m.rttHist},
+	}
+	for _, h := range histograms {
+		n, err := h.hist.writeTo(w, h.name, h.help)
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// ServeMetrics serves the metrics on /metrics and the health handler on
+// /healthz at the given address. It blocks until the server fails.
+func ServeMetrics(addr string, m *Metrics, health http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	mux.Handle("/healthz", health)
+	return http.ListenAndServe(addr, mux)
+}
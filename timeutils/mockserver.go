@@ -0,0 +1,111 @@
+package timeutils
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockNTPServer answers NTP requests with a deliberately skewed, noisy or
+// lossy time, for exercising clients in tests and labs. The jitter and drops
+// come from a seeded generator, so a run with the same seed and the same
+// requests behaves the same.
+type MockNTPServer struct {
+	Addr string
+	// Offset is added to the time of this host in every reply.
+	Offset time.Duration
+	// Jitter is the largest extra offset, drawn uniformly from [-Jitter, Jitter] per reply.
+	Jitter time.Duration
+	// Stratum is reported in the replies: 0 sends a RATE kiss of death and 16 an unsynchronized server.
+	Stratum uint8
+	// Drop is the fraction of requests left unanswered, from 0 to 1.
+	Drop float64
+	Seed int64
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// ParseFraction parses a fraction given as a percentage ("10%") or a number from 0 to 1 ("0.1").
+func ParseFraction(s string) (float64, error) {
+	value, percent := strings.CutSuffix(strings.TrimSpace(s), "%")
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a percentage or a fraction", s)
+	}
+	if percent {
+		f /= 100
+	}
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("%q is not between 0%% and 100%%", s)
+	}
+	return f, nil
+}
+
+// ListenAndServe serves until the listener fails.
+func (s *MockNTPServer) ListenAndServe() error {
+	conn, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	s.random = rand.New(rand.NewSource(s.Seed))
+	Logger.Info("serving mock NTP", "addr", s.Addr, "offset", s.Offset, "jitter", s.Jitter, "stratum", s.Stratum, "drop", s.Drop)
+
+	buffer := make([]byte, 512)
+	for {
+		n, client, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		received := time.Now()
+		ref, drop := s.reference(received)
+		if drop {
+			logTrace("dropped", "network", "ntp", "addr", s.Addr, "client", client.String())
+			continue
+		}
+		reply := ntpReply(buffer[:n], received, ref)
+		if reply == nil {
+			continue
+		}
+		conn.WriteTo(reply, client)
+		logTrace("served", "network", "ntp", "addr", s.Addr, "client", client.String(), "correction", ref.Correction)
+	}
+}
+
+// reference returns the state the reply to a request received at received is
+// built from, or whether to drop the request.
+func (s *MockNTPServer) reference(received time.Time) (ntpReference, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Drop > 0 && s.random.Float64() < s.Drop {
+		return ntpReference{}, true
+	}
+	correction := s.Offset
+	if s.Jitter > 0 {
+		correction += time.Duration((2*s.random.Float64() - 1) * float64(s.Jitter))
+	}
+
+	ref := ntpReference{
+		Stratum:        s.Stratum,
+		ReferenceID:    0x4d4f434b, // "MOCK"
+		ReferenceTime:  received.Add(-time.Minute),
+		RootDelay:      time.Millisecond,
+		RootDispersion: time.Millisecond + s.Jitter,
+		Correction:     correction,
+	}
+	switch {
+	case s.Stratum == 0:
+		ref.ReferenceID = 0x52415445 // "RATE"
+	case s.Stratum >= 16:
+		ref = unsynchronized
+		ref.Correction = correction
+	case s.Stratum > 1:
+		ref.ReferenceID = 0x7f000001 // 127.0.0.1
+	}
+	return ref, false
+}
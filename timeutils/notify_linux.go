@@ -0,0 +1,45 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	notifyObjectPath = "/io/github/earentir/ntpcl"
+	notifyInterface  = "io.github.earentir.ntpcl"
+)
+
+// NotifyTimeChanged emits D-Bus signals on the system bus after the clock was
+// set: an io.github.earentir.ntpcl.TimeChanged signal carrying the applied
+// correction and source server, and an org.freedesktop.timedate1-compatible
+// PropertiesChanged signal so listeners re-read the time properties.
+func NotifyTimeChanged(delta time.Duration, server string) error {
+	deltaUsec := strconv.FormatInt(delta.Microseconds(), 10)
+
+	if _, err := exec.LookPath("busctl"); err == nil {
+		if err := exec.Command("busctl", "--system", "emit", notifyObjectPath, notifyInterface, "TimeChanged", "xs", deltaUsec, server).Run(); err != nil {
+			return fmt.Errorf("busctl emit TimeChanged: %v", err)
+		}
+		if err := exec.Command("busctl", "--system", "emit", "/org/freedesktop/timedate1", "org.freedesktop.DBus.Properties", "PropertiesChanged",
+			"sa{sv}as", "org.freedesktop.timedate1", "0", "2", "TimeUSec", "RTCTimeUSec").Run(); err != nil {
+			return fmt.Errorf("busctl emit PropertiesChanged: %v", err)
+		}
+		return nil
+	}
+
+	// dbus-send cannot build the a{sv} payload of PropertiesChanged, so only the ntpcl signal is sent.
+	if _, err := exec.LookPath("dbus-send"); err != nil {
+		return fmt.Errorf("neither busctl nor dbus-send found")
+	}
+	if err := exec.Command("dbus-send", "--system", "--type=signal", notifyObjectPath, notifyInterface+".TimeChanged",
+		"int64:"+deltaUsec, "string:"+server).Run(); err != nil {
+		return fmt.Errorf("dbus-send TimeChanged: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package timeutils
+
+import (
+	"fmt"
+	"time"
+)
+
+// NotifyTimeChanged is only supported on Linux.
+func NotifyTimeChanged(_ time.Duration, _ string) error {
+	return fmt.Errorf("D-Bus notifications are only supported on Linux")
+}
@@ -0,0 +1,184 @@
+package timeutils
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+// ntpServerPrecision is the precision advertised in replies: -20 as a signed
+// byte, i.e. 2^-20 s (about 1µs).
+const ntpServerPrecision = 0xec
+
+// ntpReference is the synchronization state a reply is built from.
+type ntpReference struct {
+	Stratum        uint8
+	ReferenceID    uint32
+	Leap           uint8
+	ReferenceTime  time.Time
+	RootDelay      time.Duration
+	RootDispersion time.Duration
+	Correction     time.Duration // added to the local clock to get the served time
+	Smear          bool          // serve leap seconds smeared instead of announced
+}
+
+// unsynchronized is served until the first successful sync: LI=3 and stratum 16, as ntpd does.
+var unsynchronized = ntpReference{Stratum: 16, Leap: 3, ReferenceID: 0x494e4954} // "INIT"
+
+// NTPServer answers NTP client requests on the local segment with the time
+// synchronized from an upstream server, one stratum below it. A nil
+// *NTPServer ignores updates.
+type NTPServer struct {
+	Addr string
+	// Smear spreads leap seconds over 24 hours in the served time.
+	Smear bool
+
+	mu  sync.Mutex
+	ref ntpReference
+}
+
+// NewNTPServer returns a server that reports itself unsynchronized until the first Update.
+func NewNTPServer(addr string) *NTPServer {
+	return &NTPServer{Addr: addr, ref: unsynchronized}
+}
+
+// Update takes the upstream of a successful NTP measurement as the reference.
+// Unless the clock was set from it, the measured offset is applied to the
+// served time.
+func (s *NTPServer) Update(report Report) {
+	if s == nil || report.NTP == nil {
+		return
+	}
+	resp := report.NTP
+	ref := ntpReference{
+		Stratum:        min(resp.Stratum, 15) + 1,
+		ReferenceID:    upstreamReferenceID(report.Server),
+		Leap:           uint8(resp.Leap),
+		ReferenceTime:  report.LocalTime,
+		RootDelay:      resp.RootDelay + resp.RTT,
+		RootDispersion: resp.RootDispersion + report.Uncertainty(),
+	}
+	if !report.ClockSet {
+		ref.Correction = report.TimeDifference()
+	}
+
+	s.mu.Lock()
+	s.ref = ref
+	s.mu.Unlock()
+	Logger.Info("relay reference updated", "upstream", report.Server, "stratum", ref.Stratum, "correction", ref.Correction)
+}
+
+func (s *NTPServer) reference() ntpReference {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ref := s.ref
+	ref.Smear = s.Smear
+	return ref
+}
+
+// ListenAndServe serves until the listener fails.
+func (s *NTPServer) ListenAndServe() error {
+	conn, err := net.ListenPacket("udp", s.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	Logger.Info("serving NTP", "addr", s.Addr)
+
+	buffer := make([]byte, 512)
+	for {
+		n, client, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		received := time.Now()
+		reply := ntpReply(buffer[:n], received, s.reference())
+		if reply == nil {
+			continue
+		}
+		conn.WriteTo(reply, client)
+		logTrace("served", "network", "ntp", "addr", s.Addr, "client", client.String())
+	}
+}
+
+// ntpReply builds the server reply to an NTP client request received at
+// received, or returns nil for anything that is not a client request.
+func ntpReply(request []byte, received time.Time, ref ntpReference) []byte {
+	if len(request) < 48 || request[0]&0x7 != 3 {
+		return nil
+	}
+	version := request[0] >> 3 & 0x7
+	if version < 1 || version > 4 {
+		return nil
+	}
+
+	// Root dispersion grows with the time since the last sync, as the clock wanders.
+	dispersion := ref.RootDispersion
+	if !ref.ReferenceTime.IsZero() {
+		dispersion += time.Duration(float64(received.Sub(ref.ReferenceTime)) * clockWander)
+	}
+
+	leap := ref.Leap
+	served := func(t time.Time) uint64 {
+		t = t.Add(ref.Correction)
+		if ref.Smear {
+			t = SmearTime(t, leap)
+		}
+		return timeToNTPTimestamp(t)
+	}
+	// Smearing servers do not announce the leap second they absorb.
+	if ref.Smear && leap != 3 {
+		ref.Leap = 0
+	}
+
+	reply := make([]byte, 48)
+	reply[0] = ref.Leap<<6 | version<<3 | 4
+	reply[1] = ref.Stratum
+	reply[2] = request[2] // poll
+	reply[3] = ntpServerPrecision
+	binary.BigEndian.PutUint32(reply[4:8], ntpShortFormat(ref.RootDelay))
+	binary.BigEndian.PutUint32(reply[8:12], ntpShortFormat(dispersion))
+	binary.BigEndian.PutUint32(reply[12:16], ref.ReferenceID)
+	if !ref.ReferenceTime.IsZero() {
+		binary.BigEndian.PutUint64(reply[16:24], served(ref.ReferenceTime))
+	}
+	copy(reply[24:32], request[40:48]) // origin is the client's transmit timestamp
+	binary.BigEndian.PutUint64(reply[32:40], served(received))
+	binary.BigEndian.PutUint64(reply[40:48], served(time.Now()))
+	return reply
+}
+
+// upstreamReferenceID identifies the upstream server as RFC 5905 does: its IPv4
+// address, or the first four bytes of the MD5 hash of its IPv6 address.
+func upstreamReferenceID(server string) uint32 {
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return 0
+	}
+	if ip := ips[0].To4(); ip != nil {
+		return binary.BigEndian.Uint32(ip)
+	}
+	sum := md5.Sum(ips[0].To16())
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// timeToNTPTimestamp converts t to a 64-bit NTP timestamp.
+func timeToNTPTimestamp(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := (uint64(t.Nanosecond()) << 32) / 1e9
+	return seconds<<32 | fraction
+}
+
+// ntpShortFormat converts d to the 32-bit NTP short format (16.16 seconds).
+func ntpShortFormat(d time.Duration) uint32 {
+	if d < 0 {
+		d = 0
+	}
+	return uint32((uint64(d) << 16) / uint64(time.Second))
+}
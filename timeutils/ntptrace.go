@@ -0,0 +1,101 @@
+package timeutils
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultTraceHops bounds the length of a trace; NTP strata stop at 15.
+const DefaultTraceHops = 16
+
+// TraceHop is one server on the way from a server to its stratum 1 source.
+type TraceHop struct {
+	Server       string
+	Stratum      uint8
+	Offset       time.Duration
+	RootDistance time.Duration
+	ReferenceID  string
+	Err          error
+}
+
+// TraceNTP follows the chain of upstream servers from server, ntptrace style:
+// each hop's reference ID names the next one, until a stratum 1 server, an
+// upstream that cannot be queried or maxHops is reached.
+func TraceNTP(server string, maxHops int) []TraceHop {
+	var hops []TraceHop
+	seen := map[string]bool{}
+
+	for len(hops) < maxHops {
+		hop := TraceHop{Server: server}
+		result, err := queryNTPWithTimestamps(server)
+		if err == nil {
+			err = ValidateResponse(result)
+		}
+		if err != nil {
+			hop.Err = err
+			hops = append(hops, hop)
+			return hops
+		}
+
+		hop.Stratum = result.Stratum
+		hop.Offset = result.ClockOffset
+		hop.RootDistance = result.RootDistance
+		hop.ReferenceID = DescribeReferenceID(result.Response)
+		hops = append(hops, hop)
+		seen[server] = true
+		Logger.Info("trace hop", "server", server, "stratum", hop.Stratum, "reference_id", hop.ReferenceID)
+
+		if result.Stratum <= 1 {
+			return hops
+		}
+		upstream := result.ReferenceString()
+		ip := net.ParseIP(upstream).To4()
+		switch {
+		case ip == nil:
+			return hops
+		case ip.IsUnspecified(), ip[0] == 127 && ip[1] == 127:
+			// No upstream, or an ntpd reference clock driver address such as 127.127.28.0.
+			return hops
+		case seen[upstream]:
+			hops = append(hops, TraceHop{Server: upstream, Err: fmt.Errorf("loop back to %s", upstream)})
+			return hops
+		}
+		server = upstream
+	}
+	return hops
+}
+
+// FormatTrace renders the hops of a trace as a table, or as lines with PlainOutput.
+func FormatTrace(hops []TraceHop) string {
+	var buf bytes.Buffer
+	rows := make([][]string, 0, len(hops))
+	for _, hop := range hops {
+		if hop.Err != nil {
+			rows = append(rows, []string{hop.Server, "-", "-", "-", hop.Err.Error()})
+			continue
+		}
+		rows = append(rows, []string{
+			hop.Server,
+			fmt.Sprintf("%d", hop.Stratum),
+			hop.Offset.String(),
+			hop.RootDistance.String(),
+			hop.ReferenceID,
+		})
+	}
+
+	if PlainOutput {
+		for i, row := range rows {
+			if hops[i].Err != nil {
+				fmt.Fprintf(&buf, "%s: %s\n", row[0], row[4])
+				continue
+			}
+			fmt.Fprintf(&buf, "%s: stratum %s, offset %s, root distance %s, %s\n", row[0], row[1], row[2], row[3], row[4])
+		}
+		return buf.String()
+	}
+
+	renderTable(&buf, []string{"Server", "Stratum", "Offset", "Root Distance", "Reference ID"}, rows, tableStyle{noWrap: true})
+	return buf.String()
+}
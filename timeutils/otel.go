@@ -0,0 +1,211 @@
+package timeutils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OTLP span status codes and kinds.
+const (
+	otlpStatusOK       = 1
+	otlpStatusError    = 2
+	otlpSpanKindClient = 3
+)
+
+// Trace collects the spans of a single query. A nil *Trace records nothing.
+type Trace struct {
+	traceID string
+	root    *Span
+	spans   []*Span
+}
+
+// Span is a timed step of a query.
+type Span struct {
+	trace      *Trace
+	spanID     string
+	parentID   string
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+}
+
+// NewTrace starts a trace whose root span covers the whole query.
+func NewTrace(name string) *Trace {
+	t := &Trace{traceID: randomHex(16)}
+	t.root = &Span{trace: t, spanID: randomHex(8), name: name, start: time.Now(), attributes: map[string]string{}}
+	return t
+}
+
+// StartSpan starts a child span of the root span.
+func (t *Trace) StartSpan(name string) *Span {
+	if t == nil {
+		return nil
+	}
+	s := &Span{trace: t, spanID: randomHex(8), parentID: t.root.spanID, name: name, start: time.Now(), attributes: map[string]string{}}
+	t.spans = append(t.spans, s)
+	return s
+}
+
+// SetAttribute sets an attribute on the root span.
+func (t *Trace) SetAttribute(key, value string) {
+	if t == nil {
+		return
+	}
+	t.root.attributes[key] = value
+}
+
+// Finish ends the root span.
+func (t *Trace) Finish(err error) {
+	if t == nil {
+		return
+	}
+	t.root.End(err)
+}
+
+// End ends the span, marking it failed when err is not nil.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.err = err
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// OTelExporter sends metrics and spans to an OTLP/HTTP collector using the JSON encoding.
+type OTelExporter struct {
+	endpoint string
+	client   *http.Client
+	resource map[string]any
+}
+
+// NewOTelExporter creates an exporter for a collector base URL such as http://collector:4318.
+func NewOTelExporter(endpoint string) *OTelExporter {
+	hostname, _ := os.Hostname()
+	return &OTelExporter{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		resource: map[string]any{
+			"attributes": otlpAttributes(map[string]string{
+				"service.name": "ntpcl",
+				"host.name":    hostname,
+			}),
+		},
+	}
+}
+
+// Export sends the spans of the trace and, when the query succeeded, the offset and RTT metrics.
+func (e *OTelExporter) Export(trace *Trace, report *Report) error {
+	if err := e.post("/v1/traces", e.tracesPayload(trace)); err != nil {
+		return err
+	}
+	if report == nil {
+		return nil
+	}
+	return e.post("/v1/metrics", e.metricsPayload(report))
+}
+
+func (e *OTelExporter) tracesPayload(trace *Trace) map[string]any {
+	spans := []map[string]any{otlpSpan(trace.root)}
+	for _, s := range trace.spans {
+		spans = append(spans, otlpSpan(s))
+	}
+
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource":   e.resource,
+			"scopeSpans": []any{map[string]any{"scope": map[string]any{"name": "ntpcl"}, "spans": spans}},
+		}},
+	}
+}
+
+func (e *OTelExporter) metricsPayload(report *Report) map[string]any {
+	now := strconv.FormatInt(report.LocalTime.UnixNano(), 10)
+	attributes := otlpAttributes(map[string]string{"server": report.Server, "method": report.Method})
+	gauge := func(name, desc string, value float64) map[string]any {
+		return map[string]any{
+			"name":        name,
+			"description": desc,
+			"unit":        "s",
+			"gauge": map[string]any{"dataPoints": []any{map[string]any{
+				"timeUnixNano": now,
+				"asDouble":     value,
+				"attributes":   attributes,
+			}}},
+		}
+	}
+
+	return map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": e.resource,
+			"scopeMetrics": []any{map[string]any{
+				"scope": map[string]any{"name": "ntpcl"},
+				"metrics": []any{
+					gauge("ntpcl.clock.offset", "Offset of the server clock relative to the local clock.", report.TimeDifference().Seconds()),
+					gauge("ntpcl.rtt", "Round trip time of the query.", report.RTT.Seconds()),
+				},
+			}},
+		}},
+	}
+}
+
+func otlpSpan(s *Span) map[string]any {
+	span := map[string]any{
+		"traceId":           s.trace.traceID,
+		"spanId":            s.spanID,
+		"name":              s.name,
+		"kind":              otlpSpanKindClient,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttributes(s.attributes),
+		"status":            map[string]any{"code": otlpStatusOK},
+	}
+	if s.parentID != "" {
+		span["parentSpanId"] = s.parentID
+	}
+	if s.err != nil {
+		span["status"] = map[string]any{"code": otlpStatusError, "message": s.err.Error()}
+	}
+	return span
+}
+
+func otlpAttributes(attrs map[string]string) []any {
+	out := []any{}
+	for k, v := range attrs {
+		out = append(out, map[string]any{"key": k, "value": map[string]any{"stringValue": v}})
+	}
+	return out
+}
+
+func (e *OTelExporter) post(path string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.endpoint+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector returned %s for %s", resp.Status, path)
+	}
+	return nil
+}
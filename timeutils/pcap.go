@@ -0,0 +1,176 @@
+package timeutils
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pcap file constants: the nanosecond-resolution magic number and the raw IP
+// link type, so packets need no made-up Ethernet header.
+const (
+	pcapMagicNanoseconds = 0xa1b23c4d
+	pcapSnapLen          = 65535
+	pcapLinkTypeRaw      = 101
+)
+
+// PacketCapture writes the NTP packets sent and received to a pcap file. The
+// IP and UDP headers are rebuilt from the socket addresses, so no capture
+// privileges are needed.
+type PacketCapture struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Capture is where the NTP packets are written when it is not nil.
+var Capture *PacketCapture
+
+// CreatePacketCapture creates or truncates a pcap file.
+func CreatePacketCapture(path string) (*PacketCapture, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagicNanoseconds)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], pcapLinkTypeRaw)
+	if _, err := file.Write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &PacketCapture{file: file}, nil
+}
+
+// Close closes the pcap file.
+func (c *PacketCapture) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.file.Close()
+}
+
+// writePacket writes a UDP datagram from src to dst seen at t. Each packet is
+// written through, so the file is complete however the process ends.
+func (c *PacketCapture) writePacket(t time.Time, src, dst *net.UDPAddr, payload []byte) {
+	packet := udpPacket(src, dst, payload)
+	record := make([]byte, 16, 16+len(packet))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(t.Nanosecond()))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(packet)))
+	record = append(record, packet...)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.file.Write(record); err != nil {
+		Logger.Warn("failed to write to the packet capture", "file", c.file.Name(), "error", err)
+	}
+}
+
+// dialer wraps dial so that the packets of the connections it opens are captured.
+func (c *PacketCapture) dialer(dial func(localAddress, remoteAddress string) (net.Conn, error)) func(string, string) (net.Conn, error) {
+	return func(localAddress, remoteAddress string) (net.Conn, error) {
+		conn, err := dial(localAddress, remoteAddress)
+		if err != nil {
+			return nil, err
+		}
+		local, ok1 := conn.LocalAddr().(*net.UDPAddr)
+		remote, ok2 := conn.RemoteAddr().(*net.UDPAddr)
+		if !ok1 || !ok2 {
+			return conn, nil
+		}
+		return &capturedConn{Conn: conn, capture: c, local: local, remote: remote}, nil
+	}
+}
+
+// capturedConn is a connected UDP socket whose datagrams are captured.
+type capturedConn struct {
+	net.Conn
+	capture       *PacketCapture
+	local, remote *net.UDPAddr
+}
+
+func (c *capturedConn) Write(b []byte) (int, error) {
+	now := time.Now()
+	n, err := c.Conn.Write(b)
+	if err == nil {
+		c.capture.writePacket(now, c.local, c.remote, b[:n])
+	}
+	return n, err
+}
+
+func (c *capturedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if err == nil {
+		c.capture.writePacket(time.Now(), c.remote, c.local, b[:n])
+	}
+	return n, err
+}
+
+// udpPacket builds the IPv4 or IPv6 packet carrying a UDP datagram, with the
+// TTL and traffic class the queries are sent with.
+func udpPacket(src, dst *net.UDPAddr, payload []byte) []byte {
+	ttl := PacketTTL
+	if ttl == 0 {
+		ttl = 64
+	}
+	udp := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(udp[0:2], uint16(src.Port))
+	binary.BigEndian.PutUint16(udp[2:4], uint16(dst.Port))
+	binary.BigEndian.PutUint16(udp[4:6], uint16(8+len(payload)))
+	udp = append(udp, payload...)
+
+	var ip, pseudo []byte
+	if src4, dst4 := src.IP.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		ip[1] = byte(PacketDSCP << 2)
+		binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(udp)))
+		ip[8] = byte(ttl)
+		ip[9] = 17 // UDP
+		copy(ip[12:16], src4)
+		copy(ip[16:20], dst4)
+		binary.BigEndian.PutUint16(ip[10:12], internetChecksum(ip))
+		pseudo = append(append([]byte{}, src4...), dst4...)
+		pseudo = append(pseudo, 0, 17, byte(len(udp)>>8), byte(len(udp)))
+	} else {
+		ip = make([]byte, 40)
+		binary.BigEndian.PutUint32(ip[0:4], 6<<28|uint32(PacketDSCP<<2)<<20)
+		binary.BigEndian.PutUint16(ip[4:6], uint16(len(udp)))
+		ip[6] = 17 // UDP
+		ip[7] = byte(ttl)
+		copy(ip[8:24], src.IP.To16())
+		copy(ip[24:40], dst.IP.To16())
+		pseudo = append([]byte{}, ip[8:40]...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(udp)))
+		pseudo = append(pseudo, 0, 0, 0, 17)
+	}
+	checksum := internetChecksum(append(pseudo, udp...))
+	if checksum == 0 {
+		checksum = 0xffff
+	}
+	binary.BigEndian.PutUint16(udp[6:8], checksum)
+	return append(ip, udp...)
+}
+
+// internetChecksum is the RFC 1071 checksum of b.
+func internetChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
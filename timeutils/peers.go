@@ -0,0 +1,235 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const peersFile = "peers.json"
+
+// peerJitterSamples is the number of recent offsets the jitter is computed from, as in ntpd.
+const peerJitterSamples = 8
+
+// PeerStatus is what the daemon knows about one configured server, in the spirit of ntpq -p.
+type PeerStatus struct {
+	Selected      bool            `json:"selected"`
+	Stratum       uint8           `json:"stratum"`
+	ReferenceID   string          `json:"refid"`
+	Reach         uint8           `json:"reach"` // one bit per poll, 1 for a usable response
+	LastRx        time.Time       `json:"last_rx"`
+	Poll          time.Duration   `json:"poll"`
+	Delay         time.Duration   `json:"delay"`
+	Offset        time.Duration   `json:"offset"`
+	Jitter        time.Duration   `json:"jitter"`
+	RecentOffsets []time.Duration `json:"recent_offsets,omitempty"`
+}
+
+// PeerTable keeps the status of the configured servers in the state directory,
+// where the daemon updates it and the peers command reads it. A nil *PeerTable
+// records nothing.
+type PeerTable struct {
+	mu    sync.Mutex
+	path  string
+	Peers map[string]*PeerStatus
+}
+
+// LoadPeerTable reads the peer status from the state directory.
+func LoadPeerTable(dir string) (*PeerTable, error) {
+	t := &PeerTable{path: filepath.Join(dir, peersFile), Peers: map[string]*PeerStatus{}}
+	data, err := os.ReadFile(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &t.Peers); err != nil {
+		return nil, fmt.Errorf("corrupt state file: %v", err)
+	}
+	return t, nil
+}
+
+func (t *PeerTable) peer(server string) *PeerStatus {
+	p, ok := t.Peers[server]
+	if !ok {
+		p = &PeerStatus{}
+		t.Peers[server] = p
+	}
+	return p
+}
+
+// ObserveResponse records a usable response from server.
+func (t *PeerTable) ObserveResponse(server string, result *NTPResult) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p := t.peer(server)
+	p.Reach = p.Reach<<1 | 1
+	p.LastRx = time.Now()
+	p.Stratum = result.Stratum
+	p.ReferenceID = result.ReferenceString()
+	p.Poll = result.Poll
+	p.Delay = result.RTT
+	p.Offset = result.ClockOffset
+	p.RecentOffsets = append(p.RecentOffsets, result.ClockOffset)
+	if len(p.RecentOffsets) > peerJitterSamples {
+		p.RecentOffsets = p.RecentOffsets[len(p.RecentOffsets)-peerJitterSamples:]
+	}
+	p.Jitter = offsetJitter(p.RecentOffsets)
+}
+
+// ObserveFailure records a poll of server that brought no usable response.
+func (t *PeerTable) ObserveFailure(server string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.peer(server)
+	p.Reach <<= 1
+}
+
+// Select marks server as the one the clock is synchronized to.
+func (t *PeerTable) Select(server string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, p := range t.Peers {
+		p.Selected = name == server
+	}
+}
+
+// Save writes the peer status to the state directory.
+func (t *PeerTable) Save() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(t.Peers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, data, 0o600)
+}
+
+// clockWander is the frequency tolerance assumed for a free-running clock, ntpd's PHI of 15 PPM.
+const clockWander = 15e-6
+
+// Uncertainty estimates how far the clock can be from the selected server: half
+// the round trip plus the jitter of the last measurement, growing by clockWander
+// since. It reports false when no server has been selected yet.
+func (t *PeerTable) Uncertainty() (server string, uncertainty time.Duration, ok bool) {
+	if t == nil {
+		return "", 0, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, p := range t.Peers {
+		if !p.Selected || p.LastRx.IsZero() {
+			continue
+		}
+		wander := time.Duration(float64(time.Since(p.LastRx)) * clockWander)
+		return name, p.Delay/2 + p.Jitter + wander, true
+	}
+	return "", 0, false
+}
+
+// offsetJitter is the RMS of the differences between successive offsets.
+func offsetJitter(offsets []time.Duration) time.Duration {
+	if len(offsets) < 2 {
+		return 0
+	}
+	var sum float64
+	for i := 1; i < len(offsets); i++ {
+		d := float64(offsets[i] - offsets[i-1])
+		sum += d * d
+	}
+	return time.Duration(math.Sqrt(sum / float64(len(offsets)-1)))
+}
+
+// FormatPeers renders the status of servers like ntpq -p: a "*" marks the
+// selected server, reach is shown in octal and times in milliseconds.
+func (t *PeerTable) FormatPeers(servers []string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ms := func(d time.Duration) string {
+		return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+	}
+
+	var rows [][]string
+	for _, server := range servers {
+		p, ok := t.Peers[server]
+		if !ok || p.LastRx.IsZero() {
+			rows = append(rows, []string{" " + server, ".INIT.", "16", "-", "-", "0", "0.000", "0.000", "0.000"})
+			continue
+		}
+		tally := " "
+		if p.Selected {
+			tally = "*"
+		}
+		rows = append(rows, []string{
+			tally + server,
+			p.ReferenceID,
+			fmt.Sprintf("%d", p.Stratum),
+			ntpqWhen(time.Since(p.LastRx)),
+			fmt.Sprintf("%d", int(p.Poll.Seconds())),
+			fmt.Sprintf("%o", p.Reach),
+			ms(p.Delay),
+			ms(p.Offset),
+			ms(p.Jitter),
+		})
+	}
+
+	var buf bytes.Buffer
+	header := []string{"remote", "refid", "st", "when", "poll", "reach", "delay", "offset", "jitter"}
+	if PlainOutput {
+		for _, row := range rows {
+			for i, field := range row {
+				if i > 0 {
+					buf.WriteString(" ")
+				}
+				if i >= 2 {
+					field = header[i] + "=" + field
+				}
+				buf.WriteString(field)
+			}
+			buf.WriteString("\n")
+		}
+		return buf.String()
+	}
+
+	renderTable(&buf, header, rows, tableStyle{alignRight: true, ntpq: true})
+	return buf.String()
+}
+
+// ntpqWhen formats the time since the last response the way ntpq does.
+func ntpqWhen(d time.Duration) string {
+	switch {
+	case d < 2048*time.Second:
+		return fmt.Sprintf("%d", int(d.Seconds()))
+	case d < 300*time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 96*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
@@ -0,0 +1,43 @@
+package timeutils
+
+import "time"
+
+// DefaultPHCUTCOffset is TAI-UTC, which PTP hardware clocks running the PTP
+// timescale are ahead of UTC by.
+const DefaultPHCUTCOffset = 37 * time.Second
+
+// phcSamples is the number of readings taken per query, as phc2sys does.
+const phcSamples = 5
+
+// phcReading is one comparison of a PTP hardware clock with the system clock.
+type phcReading struct {
+	system time.Time // midpoint of the system clock readings around the PHC one
+	phc    time.Time
+	delay  time.Duration // between the two system clock readings
+}
+
+// bestPHCReading returns the reading with the shortest delay, the one least
+// disturbed by the time it took to read the PHC.
+func bestPHCReading(readings []phcReading) phcReading {
+	best := readings[0]
+	for _, r := range readings[1:] {
+		if r.delay < best.delay {
+			best = r
+		}
+	}
+	return best
+}
+
+// FetchTimeFromPHC compares a PTP hardware clock such as /dev/ptp0 with the
+// system clock and returns the UTC time of the PHC, taking utcOffset off its
+// time, together with the delay of the best reading.
+func FetchTimeFromPHC(device string, utcOffset time.Duration) (time.Time, time.Duration, error) {
+	readings, err := readPHC(device, phcSamples)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	best := bestPHCReading(readings)
+	offset := best.phc.Add(-utcOffset).Sub(best.system)
+	Logger.Info("PHC reading", "device", device, "phc", best.phc, "system", best.system, "delay", best.delay, "offset", offset)
+	return time.Now().Add(offset), best.delay, nil
+}
@@ -0,0 +1,73 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ptpMaxSamples and ptpSysOffset mirror PTP_MAX_SAMPLES and struct
+// ptp_sys_offset of linux/ptp_clock.h.
+const ptpMaxSamples = 25
+
+type ptpClockTime struct {
+	Sec      int64
+	Nsec     uint32
+	Reserved uint32
+}
+
+type ptpSysOffset struct {
+	Samples  uint32
+	Reserved [3]uint32
+	// System and PHC readings interleaved: sys, phc, sys, ..., phc, sys.
+	Times [2*ptpMaxSamples + 1]ptpClockTime
+}
+
+// ptpSysOffsetRequest is PTP_SYS_OFFSET, _IOW('=', 5, struct ptp_sys_offset).
+var ptpSysOffsetRequest = uintptr(1<<30 | unsafe.Sizeof(ptpSysOffset{})<<16 | '='<<8 | 5)
+
+func (t ptpClockTime) time() time.Time {
+	return time.Unix(t.Sec, int64(t.Nsec))
+}
+
+// readPHC takes samples readings of a PTP hardware clock with the PTP_SYS_OFFSET ioctl.
+func readPHC(device string, samples int) ([]phcReading, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := ptpSysOffset{Samples: uint32(samples)}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	controlErr := conn.Control(func(fd uintptr) {
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, ptpSysOffsetRequest, uintptr(unsafe.Pointer(&data)))
+		if errno != 0 {
+			err = errno
+		}
+	})
+	if controlErr != nil {
+		return nil, controlErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("PTP_SYS_OFFSET on %s: %v", device, err)
+	}
+
+	readings := make([]phcReading, samples)
+	for i := range readings {
+		before, phc, after := data.Times[2*i].time(), data.Times[2*i+1].time(), data.Times[2*i+2].time()
+		delay := after.Sub(before)
+		readings[i] = phcReading{system: before.Add(delay / 2), phc: phc, delay: delay}
+		logTrace("phc sample", "device", device, "system", readings[i].system, "phc", phc, "delay", delay)
+	}
+	return readings, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package timeutils
+
+import "fmt"
+
+// readPHC is only supported on Linux.
+func readPHC(_ string, _ int) ([]phcReading, error) {
+	return nil, fmt.Errorf("PTP hardware clocks are only supported on Linux")
+}
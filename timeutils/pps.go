@@ -0,0 +1,37 @@
+package timeutils
+
+import (
+	"fmt"
+	"time"
+)
+
+// ppsJitter is the assumed uncertainty of a kernel PPS timestamp.
+const ppsJitter = 10 * time.Microsecond
+
+// RefineWithPPS pins a coarse measurement of the clock offset to the edge of
+// the next pulse from a PPS device. The pulse marks the start of a second and
+// the coarse offset, which must be good to well under half a second, tells
+// which one. It returns the offset of the clock at the pulse and its uncertainty.
+func RefineWithPPS(device string, coarseOffset, coarseUncertainty time.Duration) (time.Duration, time.Duration, error) {
+	if coarseUncertainty >= 500*time.Millisecond {
+		return 0, 0, fmt.Errorf("the time source is too uncertain (±%v) to number the PPS pulses", coarseUncertainty)
+	}
+	assert, err := fetchPPSAssert(device, 2*time.Second)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read %s: %v", device, err)
+	}
+	if age := time.Since(assert); age > 2*time.Second || age < 0 {
+		return 0, 0, fmt.Errorf("the last pulse from %s is %v old", device, age)
+	}
+
+	// The true time at the pulse is the whole second nearest to its coarse estimate.
+	second := assert.Add(coarseOffset).Round(time.Second)
+	offset := second.Sub(assert)
+	Logger.Info("PPS pulse", "device", device, "assert", assert, "coarse_offset", coarseOffset, "offset", offset)
+	if (offset - coarseOffset).Abs() > coarseUncertainty+100*time.Millisecond {
+		if err := Warnf("PPS offset %v is %v from the time source's %v; check the PPS source and cable delay", offset, offset-coarseOffset, coarseOffset); err != nil {
+			return 0, 0, err
+		}
+	}
+	return offset, ppsJitter, nil
+}
@@ -0,0 +1,43 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// fetchPPSAssert waits up to timeout for the next pulse of a Linux PPS device
+// (RFC 2783, /dev/ppsN) and returns its assert timestamp, taken by the kernel
+// from the system clock.
+func fetchPPSAssert(device string, timeout time.Duration) (time.Time, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	data := unix.PPSFData{Timeout: unix.PPSKTime{Sec: int64(timeout / time.Second)}}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return time.Time{}, err
+	}
+	controlErr := conn.Control(func(fd uintptr) {
+		_, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, unix.PPS_FETCH, uintptr(unsafe.Pointer(&data)))
+		if errno != 0 {
+			err = errno
+		}
+	})
+	if controlErr != nil {
+		return time.Time{}, controlErr
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	logTrace("pps fetch", "device", device, "sequence", data.Info.Assert_sequence)
+	return time.Unix(data.Info.Assert_tu.Sec, int64(data.Info.Assert_tu.Nsec)), nil
+}
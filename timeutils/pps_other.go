@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package timeutils
+
+import (
+	"fmt"
+	"time"
+)
+
+// fetchPPSAssert is only supported on Linux.
+func fetchPPSAssert(_ string, _ time.Duration) (time.Time, error) {
+	return time.Time{}, fmt.Errorf("PPS devices are only supported on Linux")
+}
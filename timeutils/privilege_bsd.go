@@ -0,0 +1,19 @@
+//go:build freebsd || openbsd || netbsd
+// +build freebsd openbsd netbsd
+
+package timeutils
+
+import (
+	"errors"
+	"os"
+)
+
+// CheckSetPrivileges reports whether the process may set the clock, which on the
+// BSDs requires root. The command method escalates through sudo instead and
+// chronyd checks its own socket permissions.
+func CheckSetPrivileges(method string) error {
+	if method != SetMethodSyscall || os.Geteuid() == 0 {
+		return nil
+	}
+	return errors.New("setting the clock requires root: run with sudo or doas")
+}
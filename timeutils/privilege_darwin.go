@@ -0,0 +1,19 @@
+//go:build darwin
+// +build darwin
+
+package timeutils
+
+import (
+	"errors"
+	"os"
+)
+
+// CheckSetPrivileges reports whether the process may set the clock, which on macOS
+// requires root. The command method escalates through sudo instead and chronyd
+// checks its own socket permissions.
+func CheckSetPrivileges(method string) error {
+	if method != SetMethodSyscall || os.Geteuid() == 0 {
+		return nil
+	}
+	return errors.New("setting the clock requires administrator rights: run with sudo")
+}
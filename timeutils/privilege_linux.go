@@ -0,0 +1,63 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// capSysTime is the bit of CAP_SYS_TIME in the capability sets.
+const capSysTime = 25
+
+// CheckSetPrivileges reports whether the process may set the clock: it needs root
+// or CAP_SYS_TIME. The command method escalates through sudo instead, or su on
+// Android, and chronyd checks its own socket permissions.
+func CheckSetPrivileges(method string) error {
+	switch method {
+	case SetMethodSyscall:
+	case SetMethodCommand:
+		return checkSu()
+	default:
+		return nil
+	}
+
+	effective, err := effectiveCapabilities()
+	if err != nil {
+		// Without /proc fall back to the user id.
+		if os.Geteuid() == 0 {
+			return nil
+		}
+	} else if effective&(1<<capSysTime) != 0 {
+		return nil
+	}
+	if runtime.GOOS == "android" {
+		return ErrNotRooted
+	}
+	return errors.New("setting the clock requires root or CAP_SYS_TIME: run with sudo or grant it with `setcap cap_sys_time+ep <binary>`")
+}
+
+// effectiveCapabilities reads the effective capability set of the process from /proc.
+func effectiveCapabilities() (uint64, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("no CapEff line in /proc/self/status")
+}
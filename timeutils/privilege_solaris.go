@@ -0,0 +1,45 @@
+//go:build solaris
+// +build solaris
+
+package timeutils
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// CheckSetPrivileges reports whether the process may set the clock, which on
+// illumos and Solaris requires the sys_time privilege: root has it in the
+// global zone, other users and zones only when granted. The command method
+// escalates through sudo instead and chronyd checks its own socket permissions.
+func CheckSetPrivileges(method string) error {
+	if method != SetMethodSyscall || hasPrivilege("sys_time") {
+		return nil
+	}
+	return errors.New("setting the clock requires the sys_time privilege: run as root in the global zone, or grant it with usermod -K defaultpriv=basic,sys_time")
+}
+
+// hasPrivilege reports whether a privilege is in the effective set of the
+// process, as listed by ppriv. Root is assumed to have it when ppriv fails.
+func hasPrivilege(name string) bool {
+	out, err := exec.Command("ppriv", strconv.Itoa(os.Getpid())).Output()
+	if err != nil {
+		return os.Geteuid() == 0
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		set, ok := strings.CutPrefix(strings.TrimSpace(line), "E:")
+		if !ok {
+			continue
+		}
+		for _, priv := range strings.Split(strings.TrimSpace(set), ",") {
+			if priv == name || priv == "all" {
+				return true
+			}
+		}
+		return false
+	}
+	return os.Geteuid() == 0
+}
@@ -0,0 +1,75 @@
+//go:build windows
+// +build windows
+
+package timeutils
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// seSystemtimeName is the privilege SetSystemTime needs. The call enables it
+// itself, so it only has to be present in the process token.
+const seSystemtimeName = "SeSystemtimePrivilege"
+
+type luidAndAttributes struct {
+	LowPart    uint32
+	HighPart   int32
+	Attributes uint32
+}
+
+// CheckSetPrivileges reports whether the process token holds SE_SYSTEMTIME_NAME,
+// which both SetSystemTime and the date/time commands need.
+func CheckSetPrivileges(_ string) error {
+	held, err := hasPrivilege(seSystemtimeName)
+	if err != nil {
+		return fmt.Errorf("failed to check for %s: %v", seSystemtimeName, err)
+	}
+	if !held {
+		return errors.New("setting the clock requires the SE_SYSTEMTIME_NAME privilege: run from an elevated (Administrator) prompt")
+	}
+	return nil
+}
+
+func hasPrivilege(name string) (bool, error) {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return false, err
+	}
+	var token syscall.Token
+	if err := syscall.OpenProcessToken(process, syscall.TOKEN_QUERY, &token); err != nil {
+		return false, err
+	}
+	defer token.Close()
+
+	var want luidAndAttributes
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return false, err
+	}
+	lookup := syscall.NewLazyDLL("advapi32.dll").NewProc("LookupPrivilegeValueW")
+	if r1, _, err := lookup.Call(0, uintptr(unsafe.Pointer(namePtr)), uintptr(unsafe.Pointer(&want))); r1 == 0 {
+		return false, err
+	}
+
+	var size uint32
+	_ = syscall.GetTokenInformation(token, syscall.TokenPrivileges, nil, 0, &size)
+	if size < 4 {
+		return false, errors.New("empty token privilege list")
+	}
+	buf := make([]byte, size)
+	if err := syscall.GetTokenInformation(token, syscall.TokenPrivileges, &buf[0], size, &size); err != nil {
+		return false, err
+	}
+
+	count := *(*uint32)(unsafe.Pointer(&buf[0]))
+	entries := unsafe.Slice((*luidAndAttributes)(unsafe.Pointer(&buf[4])), count)
+	for _, entry := range entries {
+		if entry.LowPart == want.LowPart && entry.HighPart == want.HighPart {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,84 @@
+package timeutils
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// PacketTTL is the IP TTL (hop limit for IPv6) of NTP queries; 0 keeps the system default.
+var PacketTTL int
+
+// PacketDSCP is the DSCP codepoint NTP queries are marked with; 0 leaves them unmarked.
+var PacketDSCP int
+
+// ParseDSCP parses a DSCP codepoint given as a number (0-63) or a name such as
+// EF, CS6 or AF41.
+func ParseDSCP(s string) (int, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	switch {
+	case name == "EF":
+		return 46, nil
+	case name == "VA", name == "VOICE-ADMIT":
+		return 44, nil
+	case len(name) == 3 && strings.HasPrefix(name, "CS") && name[2] >= '0' && name[2] <= '7':
+		return int(name[2]-'0') * 8, nil
+	case len(name) == 4 && strings.HasPrefix(name, "AF") && name[2] >= '1' && name[2] <= '4' && name[3] >= '1' && name[3] <= '3':
+		return int(name[2]-'0')*8 + int(name[3]-'0')*2, nil
+	}
+
+	v, err := strconv.Atoi(name)
+	if err != nil || v < 0 || v > 63 {
+		return 0, fmt.Errorf("invalid DSCP %q (use 0-63, EF, CS0-CS7 or AF11-AF43)", s)
+	}
+	return v, nil
+}
+
+// qosDialer dials the UDP socket of an NTP query and applies PacketTTL and
+// PacketDSCP for the address family of the server.
+func qosDialer(localAddress, remoteAddress string) (net.Conn, error) {
+	var laddr *net.UDPAddr
+	if localAddress != "" {
+		var err error
+		laddr, err = net.ResolveUDPAddr("udp", net.JoinHostPort(localAddress, "0"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	raddr, err := net.ResolveUDPAddr("udp", remoteAddress)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", laddr, raddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if raddr.IP.To4() != nil {
+		c := ipv4.NewConn(conn)
+		if PacketTTL != 0 {
+			err = c.SetTTL(PacketTTL)
+		}
+		if err == nil && PacketDSCP != 0 {
+			err = c.SetTOS(PacketDSCP << 2)
+		}
+	} else {
+		c := ipv6.NewConn(conn)
+		if PacketTTL != 0 {
+			err = c.SetHopLimit(PacketTTL)
+		}
+		if err == nil && PacketDSCP != 0 {
+			err = c.SetTrafficClass(PacketDSCP << 2)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to mark packets to %s: %v", remoteAddress, err)
+	}
+	logTrace("udp dial", "addr", remoteAddress, "ttl", PacketTTL, "dscp", PacketDSCP)
+	return conn, nil
+}
@@ -0,0 +1,235 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const quarantineFile = "quarantine.json"
+
+// A server is quarantined after quarantineStrikes bad answers in a row: a
+// rejected response, a kiss-of-death or, with --combine, a falseticker. The
+// first quarantine lasts minQuarantine and each one that follows without a
+// good answer in between twice as long, up to maxQuarantine.
+const (
+	quarantineStrikes = 3
+	minQuarantine     = time.Hour
+	maxQuarantine     = 24 * time.Hour
+)
+
+// QuarantineEntry is what the quarantine list knows about one server.
+type QuarantineEntry struct {
+	Strikes int    `json:"strikes,omitempty"` // bad answers in a row since the last quarantine
+	Reason  string `json:"reason"`            // the last bad answer, or why it was added by hand
+	// Until is when the quarantine ends; zero for a server added by hand without a duration.
+	Until    time.Time     `json:"until,omitempty"`
+	Cooldown time.Duration `json:"cooldown,omitempty"` // length of the last quarantine
+	Manual   bool          `json:"manual,omitempty"`   // added with ntpcl servers add
+}
+
+// Quarantined reports whether the server must not be queried at now.
+func (e QuarantineEntry) Quarantined(now time.Time) bool {
+	if e.Manual && e.Until.IsZero() {
+		return true
+	}
+	return now.Before(e.Until)
+}
+
+// Quarantine is the list of servers that are not queried, kept in the state
+// directory: servers that repeatedly answered badly, for a cooldown, and
+// servers added by hand. The file is read on every use, so a daemon picks up
+// the changes of ntpcl servers add and remove. A nil *Quarantine allows every server.
+type Quarantine struct {
+	mu   sync.Mutex
+	path string
+}
+
+// OpenQuarantine returns the quarantine list of the state directory.
+func OpenQuarantine(dir string) *Quarantine {
+	return &Quarantine{path: filepath.Join(dir, quarantineFile)}
+}
+
+// Entries returns the servers on the list.
+func (q *Quarantine) Entries() (map[string]QuarantineEntry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.load()
+}
+
+func (q *Quarantine) load() (map[string]QuarantineEntry, error) {
+	entries := map[string]QuarantineEntry{}
+	data, err := os.ReadFile(q.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("corrupt state file: %v", err)
+	}
+	return entries, nil
+}
+
+// update applies change to the list and saves it. A lock file keeps the
+// daemon and the servers command from losing each other's changes.
+func (q *Quarantine) update(change func(entries map[string]QuarantineEntry)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(q.path), 0o755); err != nil {
+		return err
+	}
+	lock, err := os.OpenFile(q.path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := waitLockFile(lock); err != nil {
+		return err
+	}
+
+	entries, err := q.load()
+	if err != nil {
+		return err
+	}
+	change(entries)
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file and renamed, so a reader never sees half of it.
+	if err := os.WriteFile(q.path+".tmp", data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(q.path+".tmp", q.path)
+}
+
+// Check returns an error when server is quarantined.
+func (q *Quarantine) Check(server string) error {
+	if q == nil {
+		return nil
+	}
+	entries, err := q.Entries()
+	if err != nil {
+		Logger.Warn("failed to read the quarantine list", "error", err)
+		return nil
+	}
+	e, ok := entries[server]
+	switch {
+	case !ok || !e.Quarantined(time.Now()):
+		return nil
+	case e.Until.IsZero():
+		return fmt.Errorf("%s is quarantined by hand: %s", server, e.Reason)
+	}
+	return fmt.Errorf("%s is quarantined until %s after: %s", server, e.Until.Format(time.RFC3339), e.Reason)
+}
+
+// Strike records a bad answer from server and quarantines it after
+// quarantineStrikes of them in a row. It returns how long the quarantine
+// lasts, or 0 when the server is not quarantined.
+func (q *Quarantine) Strike(server, reason string) (time.Duration, error) {
+	if q == nil {
+		return 0, nil
+	}
+	var cooldown time.Duration
+	err := q.update(func(entries map[string]QuarantineEntry) {
+		e := entries[server]
+		if e.Manual {
+			return
+		}
+		e.Strikes++
+		e.Reason = reason
+		if e.Strikes >= quarantineStrikes {
+			e.Cooldown = min(max(2*e.Cooldown, minQuarantine), maxQuarantine)
+			e.Until = time.Now().Add(e.Cooldown)
+			e.Strikes = 0
+			cooldown = e.Cooldown
+		}
+		entries[server] = e
+	})
+	return cooldown, err
+}
+
+// Clear forgets the bad answers of server after a good one. Servers added by
+// hand stay on the list.
+func (q *Quarantine) Clear(server string) error {
+	if q == nil {
+		return nil
+	}
+	entries, err := q.Entries()
+	if e, ok := entries[server]; err != nil || !ok || e.Manual {
+		return err
+	}
+	return q.update(func(entries map[string]QuarantineEntry) {
+		if !entries[server].Manual {
+			delete(entries, server)
+		}
+	})
+}
+
+// Add quarantines server by hand for d, or until it is removed when d is 0.
+func (q *Quarantine) Add(server, reason string, d time.Duration) error {
+	return q.update(func(entries map[string]QuarantineEntry) {
+		e := QuarantineEntry{Reason: reason, Manual: true}
+		if d > 0 {
+			e.Until = time.Now().Add(d)
+		}
+		entries[server] = e
+	})
+}
+
+// Remove takes server off the list and reports whether it was on it.
+func (q *Quarantine) Remove(server string) (bool, error) {
+	var found bool
+	err := q.update(func(entries map[string]QuarantineEntry) {
+		_, found = entries[server]
+		delete(entries, server)
+	})
+	return found, err
+}
+
+// FormatQuarantine renders the list as a table, or as lines with PlainOutput.
+func FormatQuarantine(entries map[string]QuarantineEntry, now time.Time) string {
+	servers := make([]string, 0, len(entries))
+	for server := range entries {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	var rows [][]string
+	for _, server := range servers {
+		e := entries[server]
+		state, until := "watched", "-"
+		switch {
+		case e.Manual:
+			state = "added by hand"
+		case e.Quarantined(now):
+			state = "quarantined"
+		}
+		if e.Quarantined(now) {
+			until = "until removed"
+			if !e.Until.IsZero() {
+				until = e.Until.Local().Format(time.DateTime)
+			}
+		}
+		rows = append(rows, []string{server, state, until, strconv.Itoa(e.Strikes), e.Reason})
+	}
+
+	var buf bytes.Buffer
+	if PlainOutput {
+		for _, row := range rows {
+			fmt.Fprintf(&buf, "%s: %s, until %s, strikes %s: %s\n", row[0], row[1], row[2], row[3], row[4])
+		}
+		return buf.String()
+	}
+	renderTable(&buf, []string{"Server", "State", "Until", "Strikes", "Reason"}, rows, tableStyle{noWrap: true})
+	return buf.String()
+}
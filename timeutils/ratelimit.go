@@ -0,0 +1,87 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const pollStateFile = "poll-state.json"
+
+// PollLimiter enforces a minimum interval between queries to the same server,
+// across the daemon and repeated one-shot runs, by keeping the time of the last
+// query to each server in the state directory. The interval is the larger of
+// the configured minimum and the poll interval the server last advertised.
+// A nil *PollLimiter allows every query.
+type PollLimiter struct {
+	MinPoll time.Duration
+
+	mu      sync.Mutex
+	path    string
+	servers map[string]pollEntry
+}
+
+type pollEntry struct {
+	Last time.Time     `json:"last"`
+	Poll time.Duration `json:"poll,omitempty"`
+}
+
+// LoadPollLimiter reads the query history from the state directory.
+func LoadPollLimiter(dir string, minPoll time.Duration) (*PollLimiter, error) {
+	l := &PollLimiter{MinPoll: minPoll, path: filepath.Join(dir, pollStateFile), servers: map[string]pollEntry{}}
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &l.servers); err != nil {
+		return nil, fmt.Errorf("corrupt state file: %v", err)
+	}
+	return l, nil
+}
+
+// Check returns an error when server was queried less than the poll interval ago.
+func (l *PollLimiter) Check(server string) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.servers[server]
+	if !ok {
+		return nil
+	}
+	interval := max(l.MinPoll, entry.Poll)
+	if next := entry.Last.Add(interval); time.Now().Before(next) {
+		return fmt.Errorf("rate limited: %s was queried %v ago, next query allowed at %s",
+			server, time.Since(entry.Last).Round(time.Second), next.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Record notes a query to server, along with the poll interval it advertised
+// (0 when unknown), and saves the history.
+func (l *PollLimiter) Record(server string, poll time.Duration) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.servers[server] = pollEntry{Last: time.Now(), Poll: poll}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(l.servers, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, data, 0o600)
+}
@@ -0,0 +1,47 @@
+package timeutils
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// ReadOnlyEnv is the environment variable that locks the binary into read-only mode.
+const ReadOnlyEnv = "NTPCL_READONLY"
+
+// ErrReadOnly is returned by every clock-mutating function while read-only mode is active.
+var ErrReadOnly = errors.New("clock changes are disabled by read-only mode (" + ReadOnlyEnv + " or config read_only)")
+
+// ErrNotRooted is returned by CheckSetPrivileges on Android devices without
+// root or su, which fall back to read-only mode instead of failing.
+var ErrNotRooted = errors.New("setting the clock on Android requires a rooted device (root or su)")
+
+var readOnlyLocked atomic.Bool
+
+// LockReadOnly enables read-only mode for the rest of the process lifetime. It cannot be undone.
+func LockReadOnly() {
+	readOnlyLocked.Store(true)
+}
+
+// ReadOnly reports whether clock changes are disabled, either by the
+// NTPCL_READONLY environment variable or by LockReadOnly.
+func ReadOnly() bool {
+	if readOnlyLocked.Load() {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(ReadOnlyEnv))) {
+	case "", "0", "false", "no", "off":
+		return false
+	default:
+		return true
+	}
+}
+
+// checkClockWritable returns ErrReadOnly when clock changes are disabled.
+func checkClockWritable() error {
+	if ReadOnly() {
+		return ErrReadOnly
+	}
+	return nil
+}
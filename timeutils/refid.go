@@ -0,0 +1,105 @@
+package timeutils
+
+import (
+	"strings"
+
+	"github.com/beevik/ntp"
+)
+
+// referenceClocks describes the stratum-1 reference identifiers of RFC 5905
+// section 7.3 and the ones common in the wild.
+var referenceClocks = map[string]string{
+	"GOES":  "Geosynchronous Orbit Environment Satellite",
+	"GPS":   "Global Positioning System",
+	"GAL":   "Galileo Positioning System",
+	"GNSS":  "Global Navigation Satellite System",
+	"PPS":   "pulse per second",
+	"IRIG":  "Inter-Range Instrumentation Group",
+	"WWVB":  "LF radio WWVB Ft. Collins, CO 60 kHz",
+	"DCF":   "LF radio DCF77 Mainflingen, DE 77.5 kHz",
+	"HBG":   "LF radio HBG Prangins, HB 75 kHz",
+	"MSF":   "LF radio MSF Anthorn, UK 60 kHz",
+	"JJY":   "LF radio JJY Fukushima, JP 40 kHz, Saga, JP 60 kHz",
+	"LORC":  "MF radio LORAN C station, 100 kHz",
+	"TDF":   "MF radio Allouis, FR 162 kHz",
+	"CHU":   "HF radio CHU Ottawa, Ontario",
+	"WWV":   "HF radio WWV Ft. Collins, CO",
+	"WWVH":  "HF radio WWVH Kauai, HI",
+	"NIST":  "NIST telephone modem",
+	"ACTS":  "NIST telephone modem",
+	"USNO":  "USNO telephone modem",
+	"PTB":   "European telephone modem",
+	"ATOM":  "atomic clock",
+	"CESM":  "cesium clock",
+	"RBDM":  "rubidium clock",
+	"PHC":   "PTP hardware clock",
+	"PTP":   "Precision Time Protocol",
+	"SHM":   "shared memory driver",
+	"LOCL":  "undisciplined local clock",
+	"LOCAL": "undisciplined local clock",
+}
+
+// kissCodes describes the kiss-of-death codes of RFC 5905 section 7.4.
+var kissCodes = map[string]string{
+	"ACST": "the association belongs to a unicast server",
+	"AUTH": "server authentication failed",
+	"AUTO": "autokey sequence failed",
+	"BCST": "the association belongs to a broadcast server",
+	"CRYP": "cryptographic authentication or identification failed",
+	"DENY": "access denied by remote server",
+	"DROP": "lost peer in symmetric mode",
+	"RSTR": "access denied due to local policy",
+	"INIT": "the association has not yet synchronized for the first time",
+	"MCST": "the association belongs to a dynamically discovered server",
+	"NKEY": "no key found",
+	"RATE": "rate exceeded, the server has temporarily denied access",
+	"RMOT": "alteration of association from a remote host running ntpdc",
+	"STEP": "a step change in system time has occurred",
+}
+
+// DescribeReferenceID decodes the reference ID of an NTP response: the kiss
+// code for stratum 0, the reference clock for stratum 1 and the upstream
+// server for higher strata.
+func DescribeReferenceID(response *ntp.Response) string {
+	id := response.ReferenceString()
+	switch {
+	case response.Stratum == 0:
+		if id == "" {
+			return "unknown kiss code"
+		}
+		if meaning, ok := kissCodes[id]; ok {
+			return id + " (" + meaning + ")"
+		}
+		return id
+	case response.Stratum == 1:
+		if meaning, ok := referenceClocks[strings.Trim(id, ".")]; ok {
+			return id + " (" + meaning + ")"
+		}
+		return id
+	case response.Stratum >= 16:
+		// Unsynchronized servers put a code such as INIT into the reference ID.
+		if code := asciiReferenceID(response.ReferenceID); code != "" {
+			return "." + code + ". (unsynchronized)"
+		}
+		return id + " (unsynchronized)"
+	default:
+		// For IPv6 upstreams this is the first four bytes of the address's MD5 hash.
+		return id + " (upstream server)"
+	}
+}
+
+// asciiReferenceID returns the reference ID as a zero-padded ASCII code, or ""
+// when it is not one.
+func asciiReferenceID(id uint32) string {
+	b := []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	code := strings.TrimRight(string(b), "\x00")
+	if code == "" {
+		return ""
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < 'A' || code[i] > 'Z' {
+			return ""
+		}
+	}
+	return code
+}
@@ -0,0 +1,63 @@
+//go:build !minimal
+// +build !minimal
+
+package timeutils
+
+import (
+	"bytes"
+
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+)
+
+// TablesSupported reports whether this build renders tables and colors. The
+// minimal build (-tags minimal) leaves out tablewriter and fatih/color and
+// only has plain output.
+const TablesSupported = true
+
+// DefaultOutput is the output format used unless --output says otherwise.
+const DefaultOutput = "table"
+
+// DisableColor turns off the ANSI colors of FormattedOutput. They are already
+// off when NO_COLOR is set, TERM is dumb or stdout is not a terminal.
+func DisableColor() {
+	color.NoColor = true
+}
+
+func colorEnabled() bool {
+	return !color.NoColor
+}
+
+func green(s string) string  { return color.GreenString(s) }
+func yellow(s string) string { return color.YellowString(s) }
+func red(s string) string    { return color.RedString(s) }
+
+// tableStyle selects the layout of a rendered table.
+type tableStyle struct {
+	alignRight bool
+	noWrap     bool
+	// ntpq drops the column separators and keeps the header case, as ntpq -p prints.
+	ntpq bool
+}
+
+// renderTable writes rows under header as a borderless table.
+func renderTable(buf *bytes.Buffer, header []string, rows [][]string, style tableStyle) {
+	table := tablewriter.NewWriter(buf)
+	table.SetHeader(header)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	if style.alignRight {
+		table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	}
+	table.SetBorder(false)
+	if style.noWrap {
+		table.SetAutoWrapText(false)
+	}
+	if style.ntpq {
+		table.SetAutoFormatHeaders(false)
+		table.SetColumnSeparator("")
+		table.SetCenterSeparator("")
+		table.SetRowSeparator("=")
+	}
+	table.AppendBulk(rows)
+	table.Render()
+}
@@ -0,0 +1,48 @@
+//go:build minimal
+// +build minimal
+
+package timeutils
+
+import (
+	"bytes"
+	"strings"
+	"text/tabwriter"
+)
+
+// TablesSupported reports whether this build renders tables and colors. The
+// minimal build leaves out tablewriter and fatih/color and only has plain output.
+const TablesSupported = false
+
+// DefaultOutput is the output format used unless --output says otherwise.
+const DefaultOutput = "plain"
+
+// DisableColor does nothing: the minimal build has no colors.
+func DisableColor() {}
+
+func colorEnabled() bool { return false }
+
+func green(s string) string  { return s }
+func yellow(s string) string { return s }
+func red(s string) string    { return s }
+
+// tableStyle selects the layout of a rendered table.
+type tableStyle struct {
+	alignRight bool
+	noWrap     bool
+	ntpq       bool
+}
+
+// renderTable writes rows under header as aligned columns, for callers that
+// clear PlainOutput; the minimal build has no table renderer.
+func renderTable(buf *bytes.Buffer, header []string, rows [][]string, style tableStyle) {
+	var flags uint
+	if style.alignRight {
+		flags = tabwriter.AlignRight
+	}
+	w := tabwriter.NewWriter(buf, 0, 0, 2, ' ', flags)
+	w.Write([]byte(strings.Join(header, "\t") + "\t\n"))
+	for _, row := range rows {
+		w.Write([]byte(strings.Join(row, "\t") + "\t\n"))
+	}
+	w.Flush()
+}
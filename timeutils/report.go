@@ -0,0 +1,200 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Report is a snapshot of a single time measurement, used by the output formats.
+type Report struct {
+	Method       string
+	Server       string
+	ServerName   string // the name Server was resolved from, when it was given as one
+	ReverseDNS   string // the PTR name of Server, when it is an address that has one
+	ServerTime   time.Time
+	LocalTime    time.Time
+	RTT          time.Duration
+	NTP          *NTPResult
+	Precision    time.Duration // uncertainty of ServerTime, when the source reports it
+	ClockSet     bool
+	SetPrecision time.Duration   // resolution of the time applied to the clock, when it was set
+	SetSkipped   string          // why --set did not change the clock, if it was skipped
+	LeapSmear    time.Duration   // how far a server smearing a leap second is from UTC, when detected
+	Geo          *GeoInfo        // location and network of the server, with --geoip-db
+	Addresses    []AddressResult // the addresses of ServerName queried, with --ip-policy all or after failures
+	Combined     []string        // the servers whose offsets were combined, with --combine
+	Falsetickers []string        // the servers left out of the combined offset, with --combine
+}
+
+// NewReport captures the local time and builds a report for a fetched server time.
+func NewReport(method string, serverTime time.Time, rtt time.Duration, server string, ntpResult *NTPResult) Report {
+	return Report{
+		Method:     method,
+		Server:     server,
+		ServerTime: serverTime,
+		LocalTime:  Now(),
+		RTT:        rtt,
+		NTP:        ntpResult,
+	}
+}
+
+// NTPResponse returns the underlying NTP response, or nil for other methods.
+func (r Report) NTPResponse() *ntp.Response {
+	if r.NTP == nil {
+		return nil
+	}
+	return r.NTP.Response
+}
+
+// TimeDifference returns the difference between the server time and the local time.
+func (r Report) TimeDifference() time.Duration {
+	return r.ServerTime.Sub(r.LocalTime)
+}
+
+// Uncertainty returns the uncertainty of the measurement: the precision reported
+// by the source or, without one, half the round trip.
+func (r Report) Uncertainty() time.Duration {
+	if r.Precision > 0 {
+		return r.Precision
+	}
+	return r.RTT / 2
+}
+
+// Summary describes the measurement in one plain sentence, e.g. "Your clock is
+// 1.8 seconds behind pool.ntp.org (measurement uncertainty ±12ms)."
+func (r Report) Summary() string {
+	server := r.Server
+	if server == "" {
+		server = "the server"
+	}
+	offset := r.TimeDifference()
+	uncertainty := r.Uncertainty()
+
+	if offset.Abs() <= uncertainty {
+		return fmt.Sprintf("Your clock agrees with %s to within the measurement uncertainty of ±%s.", server, humanDuration(uncertainty))
+	}
+	direction := "behind"
+	if offset < 0 {
+		direction = "ahead of"
+	}
+	return fmt.Sprintf("Your clock is %s %s %s (measurement uncertainty ±%s).", humanDuration(offset.Abs()), direction, server, humanDuration(uncertainty))
+}
+
+// humanDuration rounds d to a readable precision, spelling out whole seconds.
+func humanDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		seconds := d.Round(100 * time.Millisecond).Seconds()
+		if seconds == 1 {
+			return "1 second"
+		}
+		return strconv.FormatFloat(seconds, 'f', -1, 64) + " seconds"
+	case d >= 10*time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+type jsonReport struct {
+	Method         string              `json:"method"`
+	Server         string              `json:"server,omitempty"`
+	ServerName     string              `json:"server_name,omitempty"`
+	ReverseDNS     string              `json:"reverse_dns,omitempty"`
+	ServerTime     time.Time           `json:"server_time"`
+	LocalTime      time.Time           `json:"local_time"`
+	TimeDifference float64             `json:"time_difference_seconds"`
+	RTT            float64             `json:"rtt_seconds"`
+	Precision      float64             `json:"precision_seconds,omitempty"`
+	ClockSet       bool                `json:"clock_set"`
+	SetPrecision   float64             `json:"set_precision_seconds,omitempty"`
+	SetSkipped     string              `json:"set_skipped,omitempty"`
+	TAIUTC         float64             `json:"tai_utc_seconds"`
+	LeapSmear      float64             `json:"leap_smear_seconds,omitempty"`
+	Geo            *GeoInfo            `json:"geo,omitempty"`
+	Addresses      []jsonAddressResult `json:"addresses,omitempty"`
+	Combined       []string            `json:"combined_servers,omitempty"`
+	Falsetickers   []string            `json:"falsetickers,omitempty"`
+	NTP            *jsonNTP            `json:"ntp,omitempty"`
+}
+
+type jsonNTP struct {
+	// SystemPeer is the server these details come from when the time combines
+	// several servers, whose offset is not the combined one.
+	SystemPeer     string    `json:"system_peer,omitempty"`
+	Version        int       `json:"version"`
+	Stratum        uint8     `json:"stratum"`
+	ReferenceID    string    `json:"reference_id"`
+	LeapIndicator  uint8     `json:"leap_indicator"`
+	Precision      float64   `json:"precision_seconds"`
+	RootDelay      float64   `json:"root_delay_seconds"`
+	RootDispersion float64   `json:"root_dispersion_seconds"`
+	ClockOffset    float64   `json:"clock_offset_seconds"`
+	Poll           float64   `json:"poll_seconds"`
+	ReferenceTime  time.Time `json:"reference_time"`
+	T1             time.Time `json:"t1"`
+	T2             time.Time `json:"t2"`
+	T3             time.Time `json:"t3"`
+	T4             time.Time `json:"t4"`
+	Theta          float64   `json:"theta_seconds"`
+	Delta          float64   `json:"delta_seconds"`
+}
+
+// MarshalJSON renders durations as seconds and includes the raw NTP timestamps when available.
+func (r Report) MarshalJSON() ([]byte, error) {
+	out := jsonReport{
+		Method:         r.Method,
+		Server:         r.Server,
+		ServerName:     r.ServerName,
+		ReverseDNS:     r.ReverseDNS,
+		ServerTime:     r.ServerTime,
+		LocalTime:      r.LocalTime,
+		TimeDifference: r.TimeDifference().Seconds(),
+		RTT:            r.RTT.Seconds(),
+		Precision:      r.Precision.Seconds(),
+		ClockSet:       r.ClockSet,
+		SetPrecision:   r.SetPrecision.Seconds(),
+		SetSkipped:     r.SetSkipped,
+		TAIUTC:         Leaps.TAIOffset(r.ServerTime).Seconds(),
+		LeapSmear:      r.LeapSmear.Seconds(),
+		Geo:            r.Geo,
+		Combined:       r.Combined,
+		Falsetickers:   r.Falsetickers,
+	}
+	for _, result := range r.Addresses {
+		out.Addresses = append(out.Addresses, result.jsonValue())
+	}
+
+	if r.NTP != nil {
+		ts := r.NTP.Timestamps
+		out.NTP = &jsonNTP{
+			Version:        r.NTP.Version,
+			Stratum:        r.NTP.Stratum,
+			ReferenceID:    r.NTP.ReferenceString(),
+			LeapIndicator:  uint8(r.NTP.Leap),
+			Precision:      r.NTP.Precision.Seconds(),
+			RootDelay:      r.NTP.RootDelay.Seconds(),
+			RootDispersion: r.NTP.RootDispersion.Seconds(),
+			ClockOffset:    r.NTP.ClockOffset.Seconds(),
+			Poll:           r.NTP.Poll.Seconds(),
+			ReferenceTime:  ts.Reference,
+			T1:             ts.T1,
+			T2:             ts.T2,
+			T3:             ts.T3,
+			T4:             ts.T4,
+			Theta:          ts.Theta.Seconds(),
+			Delta:          ts.Delta.Seconds(),
+		}
+		if len(r.Combined) > 0 {
+			out.NTP.SystemPeer = r.Server
+		}
+	}
+
+	return json.Marshal(out)
+}
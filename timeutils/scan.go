@@ -0,0 +1,200 @@
+package timeutils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// maxScanHosts keeps a mistyped prefix from probing a whole /8.
+const maxScanHosts = 65536
+
+// MaxScanRate is the highest probe rate a scan is paced at; the pacing ticker
+// cannot tick faster than once a nanosecond.
+const MaxScanRate = 1000000
+
+// ScanOptions controls the pace of a scan.
+type ScanOptions struct {
+	Concurrency int           // hosts probed at the same time
+	Rate        int           // probes sent per second, up to MaxScanRate; 0 is unlimited
+	Timeout     time.Duration // per probe
+}
+
+// ScanResult lists the time services a host answered on.
+type ScanResult struct {
+	Host        string
+	NTP         bool
+	Stratum     uint8
+	ReferenceID string
+	Daytime     bool
+	Time        bool
+}
+
+// ScanNetwork probes every host of a CIDR prefix for NTP (UDP/123), daytime
+// (TCP/13) and time (UDP/37) responders and returns the hosts that answered.
+func ScanNetwork(prefix string, opts ScanOptions) ([]ScanResult, error) {
+	hosts, err := scanHosts(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	// Every probe waits for a token, which caps the packet rate of the whole scan.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tokens := make(chan struct{})
+	go func() {
+		var tick <-chan time.Time
+		if opts.Rate > 0 {
+			ticker := time.NewTicker(time.Second / time.Duration(opts.Rate))
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	jobs := make(chan netip.Addr)
+	var mu sync.Mutex
+	var results []ScanResult
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				if result, ok := probeHost(addr.String(), tokens, opts.Timeout); ok {
+					mu.Lock()
+					results = append(results, result)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, addr := range hosts {
+		jobs <- addr
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		a, _ := netip.ParseAddr(results[i].Host)
+		b, _ := netip.ParseAddr(results[j].Host)
+		return a.Less(b)
+	})
+	return results, nil
+}
+
+// scanHosts lists the host addresses of a prefix, leaving out the network and
+// broadcast addresses of IPv4 prefixes shorter than /31. A plain address scans that host.
+func scanHosts(prefix string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(prefix); err == nil {
+		return []netip.Addr{addr}, nil
+	}
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+	p = p.Masked()
+	if hostBits := p.Addr().BitLen() - p.Bits(); hostBits > 16 {
+		return nil, fmt.Errorf("%s has more than %d addresses", prefix, maxScanHosts)
+	}
+
+	var hosts []netip.Addr
+	for addr := p.Addr(); p.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr)
+	}
+	if p.Addr().Is4() && p.Bits() < 31 && len(hosts) > 2 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts, nil
+}
+
+// probeHost sends the three probes to host and reports whether any was answered.
+func probeHost(host string, tokens <-chan struct{}, timeout time.Duration) (ScanResult, bool) {
+	result := ScanResult{Host: host}
+
+	<-tokens
+	opts := ntpQueryOptions(host)
+	opts.Timeout = timeout
+	if response, err := ntp.QueryWithOptions(host, opts); err == nil {
+		result.NTP = true
+		result.Stratum = response.Stratum
+		result.ReferenceID = DescribeReferenceID(response)
+	}
+
+	<-tokens
+	if conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "13"), timeout); err == nil {
+		conn.SetDeadline(time.Now().Add(timeout))
+		data, _ := io.ReadAll(io.LimitReader(conn, 1024))
+		conn.Close()
+		result.Daytime = len(data) > 0
+	}
+
+	<-tokens
+	if conn, err := net.Dial("udp", net.JoinHostPort(host, "37")); err == nil {
+		conn.SetDeadline(time.Now().Add(timeout))
+		buffer := make([]byte, 4)
+		if _, err := conn.Write(nil); err == nil {
+			n, _ := conn.Read(buffer)
+			result.Time = n == 4
+		}
+		conn.Close()
+	}
+
+	Logger.Debug("probed host", "host", host, "ntp", result.NTP, "daytime", result.Daytime, "time", result.Time)
+	return result, result.NTP || result.Daytime || result.Time
+}
+
+// FormatScan renders the hosts found by a scan as a table, or as lines with PlainOutput.
+func FormatScan(results []ScanResult) string {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "-"
+	}
+
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		stratum, refid := "-", "-"
+		if r.NTP {
+			stratum = fmt.Sprintf("%d", r.Stratum)
+			refid = r.ReferenceID
+		}
+		rows = append(rows, []string{r.Host, stratum, refid, yesNo(r.Daytime), yesNo(r.Time)})
+	}
+
+	var buf bytes.Buffer
+	if PlainOutput {
+		for _, row := range rows {
+			fmt.Fprintf(&buf, "%s: stratum %s, refid %s, daytime %s, time %s\n", row[0], row[1], row[2], row[3], row[4])
+		}
+		return buf.String()
+	}
+
+	renderTable(&buf, []string{"Host", "NTP Stratum", "Reference ID", "Daytime", "Time"}, rows, tableStyle{noWrap: true})
+	return buf.String()
+}
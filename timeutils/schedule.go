@@ -0,0 +1,249 @@
+package timeutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a five-field cron expression: minute, hour, day of month,
+// month and day of week, evaluated in local time.
+type Schedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64 // bit sets of the allowed values
+	domAny, dowAny                bool
+}
+
+var (
+	monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	dayNames   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// ParseSchedule parses a cron expression such as "*/15 * * * *". Fields take
+// *, values, ranges (1-5), steps (*/15, 0-30/10) and lists of them; months and
+// days of the week may be given by name (jan, mon). The shortcuts @hourly,
+// @daily, @weekly and @monthly are accepted too.
+func ParseSchedule(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	switch strings.ToLower(expr) {
+	case "@hourly":
+		fields = strings.Fields("0 * * * *")
+	case "@daily", "@midnight":
+		fields = strings.Fields("0 0 * * *")
+	case "@weekly":
+		fields = strings.Fields("0 0 * * 0")
+	case "@monthly":
+		fields = strings.Fields("0 0 1 * *")
+	}
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q does not have 5 fields (minute hour day-of-month month day-of-week)", expr)
+	}
+	s := &Schedule{expr: expr, domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	for _, f := range []struct {
+		name     string
+		value    string
+		min, max int
+		names    map[string]int
+		dst      *uint64
+	}{
+		{"minute", fields[0], 0, 59, nil, &s.minute},
+		{"hour", fields[1], 0, 23, nil, &s.hour},
+		{"day of month", fields[2], 1, 31, nil, &s.dom},
+		{"month", fields[3], 1, 12, monthNames, &s.month},
+		{"day of week", fields[4], 0, 7, dayNames, &s.dow},
+	} {
+		bits, err := parseCronField(f.value, f.min, f.max, f.names)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		*f.dst = bits
+	}
+	// Both 0 and 7 are Sunday.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("%q never matches", expr)
+	}
+	return s, nil
+}
+
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rangePart != "*" {
+			first, last, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = cronValue(first, min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = cronValue(last, min, max, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", rangePart)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%q is not a value from %d to %d", s, min, max)
+	}
+	return v, nil
+}
+
+// String returns the expression the schedule was parsed from.
+func (s *Schedule) String() string {
+	if s == nil {
+		return ""
+	}
+	return s.expr
+}
+
+// Next returns the first time after t that matches the schedule, or the zero
+// time when none does within five years (such as February 30).
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches applies the cron rule that when both the day of month and the
+// day of week are restricted, a day matching either runs.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// QuietWindow is a recurring period during which the clock is not stepped,
+// such as trading hours: "Mon-Fri 09:30-16:00 America/New_York". The days
+// and the zone are optional; windows may cross midnight.
+type QuietWindow struct {
+	expr       string
+	days       uint64 // bit set of weekdays, Sunday is 0
+	start, end time.Duration
+	location   *time.Location
+}
+
+// ParseQuietWindow parses "[DAYS] HH:MM-HH:MM [ZONE]", where DAYS is a list
+// of day names or ranges (Mon-Fri,Sun) and ZONE an IANA name (local time by default).
+func ParseQuietWindow(expr string) (QuietWindow, error) {
+	w := QuietWindow{expr: expr, days: 0x7f, location: time.Local}
+	fields := strings.Fields(expr)
+	if len(fields) > 0 && !strings.Contains(fields[0], ":") {
+		days, err := parseCronField(fields[0], 0, 7, dayNames)
+		if err != nil {
+			return w, fmt.Errorf("quiet window %q: days: %v", expr, err)
+		}
+		if days&(1<<7) != 0 {
+			days |= 1
+		}
+		w.days = days & 0x7f
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return w, fmt.Errorf("quiet window %q is not [DAYS] HH:MM-HH:MM [ZONE]", expr)
+	}
+	from, to, ok := strings.Cut(fields[0], "-")
+	var err1, err2 error
+	w.start, err1 = parseClockTime(from)
+	w.end, err2 = parseClockTime(to)
+	if !ok || err1 != nil || err2 != nil || w.start == w.end {
+		return w, fmt.Errorf("quiet window %q: invalid time range %q", expr, fields[0])
+	}
+	if len(fields) == 2 {
+		location, err := time.LoadLocation(fields[1])
+		if err != nil {
+			return w, fmt.Errorf("quiet window %q: %v", expr, err)
+		}
+		w.location = location
+	}
+	return w, nil
+}
+
+// parseClockTime parses HH:MM as the time since midnight.
+func parseClockTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String returns the expression the window was parsed from.
+func (w QuietWindow) String() string {
+	return w.expr
+}
+
+// Contains reports whether t falls in the window. The part of a window that
+// crosses midnight belongs to the day it started on.
+func (w QuietWindow) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, w.location)
+	sinceMidnight := t.Sub(midnight)
+	if w.start < w.end {
+		return w.days&(1<<uint(t.Weekday())) != 0 && sinceMidnight >= w.start && sinceMidnight < w.end
+	}
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.days&(1<<uint(t.Weekday())) != 0 && sinceMidnight >= w.start) ||
+		(w.days&(1<<uint(yesterday)) != 0 && sinceMidnight < w.end)
+}
+
+// InQuietWindow returns the window t falls in, if any.
+func InQuietWindow(windows []QuietWindow, t time.Time) (QuietWindow, bool) {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return w, true
+		}
+	}
+	return QuietWindow{}, false
+}
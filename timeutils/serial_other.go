@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package timeutils
+
+import (
+	"fmt"
+	"os"
+)
+
+// configureSerial is only supported on Linux; elsewhere the line must be set
+// up beforehand (e.g. with stty) and used with a baud of 0.
+func configureSerial(_ *os.File, baud int) error {
+	if baud == 0 {
+		return nil
+	}
+	return fmt.Errorf("setting the baud rate is only supported on Linux; configure the port beforehand and use --baud 0")
+}
@@ -0,0 +1,72 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// HTTPTimeServer answers every request with the time of this host: in the Date
+// header and in a JSON body with sub-second precision. The uncertainty is taken
+// from the peer status the daemon keeps in StateDir.
+type HTTPTimeServer struct {
+	Addr     string
+	StateDir string
+	Smear    bool // spread leap seconds over 24 hours
+}
+
+type httpTimeResponse struct {
+	UnixTime     float64  `json:"unixtime"`
+	ISO8601      string   `json:"iso8601"`
+	Uncertainty  *float64 `json:"uncertainty_seconds"` // null while the clock has not been synchronized
+	Synchronized bool     `json:"synchronized"`
+	Server       string   `json:"server,omitempty"`
+}
+
+// ListenAndServe serves until the listener fails.
+func (s *HTTPTimeServer) ListenAndServe() error {
+	Logger.Info("serving time over HTTP", "addr", s.Addr)
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
+
+func (s *HTTPTimeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body httpTimeResponse
+	if peers, err := LoadPeerTable(s.StateDir); err != nil {
+		Logger.Warn("failed to load the peer status", "err", err)
+	} else if server, uncertainty, ok := peers.Uncertainty(); ok {
+		seconds := uncertainty.Seconds()
+		body.Uncertainty = &seconds
+		body.Synchronized = true
+		body.Server = server
+	}
+
+	// The Date header and the body are taken from the same reading of the clock.
+	now := time.Now().UTC()
+	if s.Smear {
+		now = SmearTime(now, 0)
+	}
+	body.UnixTime = float64(now.UnixNano()) / 1e9
+	body.ISO8601 = now.Format(time.RFC3339Nano)
+
+	header := w.Header()
+	header.Set("Date", now.Format(http.TimeFormat))
+	header.Set("Content-Type", "application/json")
+	header.Set("Cache-Control", "no-store")
+	header.Set("Access-Control-Allow-Origin", "*")
+	if r.Method == http.MethodHead {
+		return
+	}
+	json.NewEncoder(w).Encode(body)
+	logTrace("served", "network", "http", "client", r.RemoteAddr)
+}
@@ -0,0 +1,115 @@
+package timeutils
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultDaytimeLayout is the ctime-style layout most inetd daytime services answer with.
+const DefaultDaytimeLayout = "Mon Jan _2 15:04:05 2006"
+
+// LegacyServer answers RFC 867 daytime and RFC 868 time protocol requests
+// over both TCP and UDP.
+type LegacyServer struct {
+	// Host is the address to listen on; empty listens on all addresses.
+	Host        string
+	DaytimePort string
+	TimePort    string
+	// Layout and Location format the daytime responses.
+	Layout   string
+	Location *time.Location
+	// Smear spreads leap seconds over 24 hours in both services.
+	Smear bool
+}
+
+// ListenAndServe serves until one of the listeners fails.
+func (s *LegacyServer) ListenAndServe() error {
+	daytimeAddr := net.JoinHostPort(s.Host, s.DaytimePort)
+	timeAddr := net.JoinHostPort(s.Host, s.TimePort)
+
+	errs := make(chan error, 4)
+	serve := func(network, addr string, respond func() []byte) {
+		if network == "tcp" {
+			errs <- serveTCP(addr, respond)
+		} else {
+			errs <- serveUDP(addr, respond)
+		}
+	}
+	go serve("tcp", daytimeAddr, s.daytime)
+	go serve("udp", daytimeAddr, s.daytime)
+	go serve("tcp", timeAddr, s.timeProtocol)
+	go serve("udp", timeAddr, s.timeProtocol)
+	Logger.Info("serving daytime and time protocol", "daytime", daytimeAddr, "time", timeAddr)
+	return <-errs
+}
+
+func (s *LegacyServer) daytime() []byte {
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	layout := s.Layout
+	if layout == "" {
+		layout = DefaultDaytimeLayout
+	}
+	return []byte(s.now().In(loc).Format(layout) + "\r\n")
+}
+
+// timeProtocol is the current time as seconds since 1900, big endian.
+func (s *LegacyServer) timeProtocol() []byte {
+	buffer := make([]byte, 4)
+	binary.BigEndian.PutUint32(buffer, uint32(s.now().Unix()+ntpEpochOffset))
+	return buffer
+}
+
+func (s *LegacyServer) now() time.Time {
+	if s.Smear {
+		return SmearTime(time.Now(), 0)
+	}
+	return time.Now()
+}
+
+// serveTCP writes the response to every connection and closes it.
+func serveTCP(addr string, respond func() []byte) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			conn.Write(respond())
+			logTrace("served", "network", "tcp", "addr", addr, "client", conn.RemoteAddr().String())
+		}()
+	}
+}
+
+// serveUDP answers every datagram, whatever it contains, with the response.
+func serveUDP(addr string, respond func() []byte) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	buffer := make([]byte, 512)
+	for {
+		_, client, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		conn.WriteTo(respond(), client)
+		logTrace("served", "network", "udp", "addr", addr, "client", client.String())
+	}
+}
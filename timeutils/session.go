@@ -0,0 +1,238 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// Exchange kinds; the other sources record under their method, e.g. "HTTP".
+const (
+	ExchangeResolve = "resolve"
+	ExchangeReverse = "reverse"
+	ExchangeNTP     = "NTP"
+)
+
+// Exchange is one recorded exchange with a time source or the resolver.
+type Exchange struct {
+	Kind   string `json:"kind"` // resolve, reverse, or the method of the source
+	Server string `json:"server"`
+	// Addresses are what a resolve returned, in the order they are preferred.
+	Addresses []string `json:"addresses,omitempty"`
+	// Name is what a reverse lookup returned.
+	Name string `json:"name,omitempty"`
+	// LocalTime is when the answer arrived: T4 for NTP.
+	LocalTime time.Time `json:"local_time"`
+	// SentTime is when the query was sent: T1 for NTP.
+	SentTime *time.Time `json:"sent_time,omitempty"`
+	// RoundTrip is the time from the query to the answer: T4 - T1 for NTP.
+	RoundTrip time.Duration `json:"round_trip,omitempty"`
+	// Packet is the raw NTP response, from which T2, T3 and the header fields are taken.
+	Packet []byte `json:"packet,omitempty"`
+	// Offset and Precision are what the other sources measured.
+	Offset    time.Duration `json:"offset,omitempty"`
+	Precision time.Duration `json:"precision,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Session is a capture of the exchanges of a run, written with --record and
+// answered from with --replay so the same responses go through the selection
+// and offset computation again offline.
+type Session struct {
+	Recorded  time.Time  `json:"recorded"`
+	Args      []string   `json:"args"`
+	Exchanges []Exchange `json:"exchanges"`
+
+	mu       sync.Mutex
+	replayed []bool
+	now      time.Time // local time of the exchange replayed last
+}
+
+// Recording collects the exchanges of the run; nothing is recorded when it is nil.
+var Recording *Session
+
+// Replaying answers the queries from a recorded session instead of the network when it is not nil.
+var Replaying *Session
+
+// NewSession starts recording a run with the given command line arguments.
+func NewSession(args []string) *Session {
+	return &Session{Recorded: time.Now(), Args: args}
+}
+
+// LoadSession reads a session written by Save for replay.
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	s.replayed = make([]bool, len(s.Exchanges))
+	return s, nil
+}
+
+// Save writes the session to path.
+func (s *Session) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Record appends an exchange. A nil *Session records nothing.
+func (s *Session) Record(e Exchange) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Exchanges = append(s.Exchanges, e)
+}
+
+// Next returns the first exchange of kind with server that has not been
+// replayed yet; an empty server matches any.
+func (s *Session) Next(kind, server string) (Exchange, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.Exchanges {
+		if s.replayed[i] || e.Kind != kind || (server != "" && e.Server != server) {
+			continue
+		}
+		s.replayed[i] = true
+		if kind != ExchangeResolve && kind != ExchangeReverse {
+			s.now = e.LocalTime
+		}
+		Logger.Debug("replayed exchange", "kind", kind, "server", e.Server, "local_time", e.LocalTime)
+		return e, nil
+	}
+	if server == "" {
+		return Exchange{}, fmt.Errorf("the session has no %s exchange left to replay", kind)
+	}
+	return Exchange{}, fmt.Errorf("the session has no %s exchange with %s left to replay", kind, server)
+}
+
+// Err returns the recorded error of the exchange, or nil if it succeeded.
+func (e Exchange) Err() error {
+	if e.Error == "" {
+		return nil
+	}
+	return errors.New(e.Error)
+}
+
+// Now returns the current time or, while replaying, the local time of the
+// source exchange replayed last, so that offsets come out as they were recorded.
+func Now() time.Time {
+	if s := Replaying; s != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.now.IsZero() {
+			return s.now
+		}
+	}
+	return time.Now()
+}
+
+// recordNTP records an NTP exchange with T1 and T4 as measured by capture
+// and the raw response, if one arrived.
+func recordNTP(server string, capture *receiveTimeCapture, err error) {
+	if Recording == nil {
+		return
+	}
+	e := Exchange{Kind: ExchangeNTP, Server: server, LocalTime: time.Now(), Packet: capture.packet}
+	if !capture.arrivalTime.IsZero() {
+		e.LocalTime = capture.arrivalTime
+	}
+	if sent := capture.transmitTime; !sent.IsZero() {
+		e.SentTime = &sent
+		e.RoundTrip = e.LocalTime.Sub(sent)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	Recording.Record(e)
+}
+
+// replayNTP answers an NTP query from the session, decoding the recorded
+// response as the ntp package would have.
+func replayNTP(server string) (*NTPResult, error) {
+	e, err := Replaying.Next(ExchangeNTP, server)
+	if err != nil {
+		return nil, err
+	}
+	if e.Error != "" {
+		return nil, e.Err()
+	}
+	if len(e.Packet) < 48 {
+		return nil, fmt.Errorf("recorded NTP response from %s is too short: %d bytes", server, len(e.Packet))
+	}
+	// Fixed-point values are rounded to the nanosecond as the ntp package
+	// does, so the fields come out as in the recorded run.
+	fixed := func(seconds, fraction uint64, bits uint) time.Duration {
+		frac := fraction * uint64(time.Second)
+		nanos := frac >> bits
+		if frac&(1<<bits-1) >= 1<<(bits-1) {
+			nanos++
+		}
+		return time.Duration(seconds*uint64(time.Second) + nanos)
+	}
+	timestamp := func(b []byte) time.Time {
+		v := binary.BigEndian.Uint64(b)
+		return time.Unix(-ntpEpochOffset, 0).UTC().Add(fixed(v>>32, v&0xffffffff, 32))
+	}
+	shortFormat := func(b []byte) time.Duration {
+		v := uint64(binary.BigEndian.Uint32(b))
+		return fixed(v>>16, v&0xffff, 16)
+	}
+	interval := func(exponent int8) time.Duration {
+		if exponent < 0 {
+			return time.Second >> uint(-exponent)
+		}
+		return time.Second << uint(exponent)
+	}
+
+	p := e.Packet
+	// Sessions recorded before SentTime have only the round trip.
+	t1, t4 := e.LocalTime.Add(-e.RoundTrip), e.LocalTime
+	if e.SentTime != nil {
+		t1 = *e.SentTime
+	}
+	// T2 is truncated as receiveTimeCapture takes it.
+	t2, t3 := ntpTimestampToTime(binary.BigEndian.Uint64(p[32:40])), timestamp(p[40:48])
+	theta := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delta := max(t4.Sub(t1)-t3.Sub(t2), 0)
+	response := &ntp.Response{
+		Time:           t3,
+		ClockOffset:    theta,
+		RTT:            delta,
+		Precision:      interval(int8(p[3])),
+		Version:        int(p[0] >> 3 & 0x7),
+		Stratum:        p[1],
+		ReferenceID:    binary.BigEndian.Uint32(p[12:16]),
+		ReferenceTime:  timestamp(p[16:24]),
+		RootDelay:      shortFormat(p[4:8]),
+		RootDispersion: shortFormat(p[8:12]),
+		Leap:           ntp.LeapIndicator(p[0] >> 6),
+		MinError:       max(t1.Sub(t2), t3.Sub(t4), 0),
+		Poll:           interval(int8(p[2])),
+	}
+	response.RootDistance = (delta+response.RootDelay)/2 + response.RootDispersion
+	if response.Stratum == 0 && !bytes.ContainsFunc(p[12:16], func(r rune) bool { return r < 32 || r > 126 }) {
+		response.KissCode = string(p[12:16])
+	}
+	return &NTPResult{
+		Response:   response,
+		Timestamps: NTPTimestamps{T1: t1, T2: t2, T3: t3, T4: t4, Reference: response.ReferenceTime, Theta: theta, Delta: delta},
+	}, nil
+}
@@ -0,0 +1,29 @@
+//go:build solaris && cgo
+// +build solaris,cgo
+
+package timeutils
+
+/*
+#include <sys/time.h>
+*/
+import "C"
+
+import "time"
+
+// solarisSetMethod is the default set method: the system call, as libc is linked in.
+const solarisSetMethod = SetMethodSyscall
+
+// SetSystemTime sets the system time on illumos and Solaris using settimeofday.
+// The system calls are only reachable through libc there, hence cgo.
+func SetSystemTime(t time.Time) error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+
+	usec := t.UnixNano() / int64(time.Microsecond)
+	tv := C.struct_timeval{tv_sec: C.time_t(usec / 1e6), tv_usec: C.suseconds_t(usec % 1e6)}
+	if _, err := C.settimeofday(&tv, nil); err != nil {
+		return err
+	}
+	return nil
+}
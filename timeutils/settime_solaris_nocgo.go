@@ -0,0 +1,21 @@
+//go:build solaris && !cgo
+// +build solaris,!cgo
+
+package timeutils
+
+import (
+	"errors"
+	"time"
+)
+
+// solarisSetMethod is the default set method: date, as builds without cgo
+// cannot reach settimeofday, which illumos and Solaris only export from libc.
+const solarisSetMethod = SetMethodCommand
+
+// SetSystemTime is not available in builds without cgo on illumos and Solaris.
+func SetSystemTime(time.Time) error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+	return errors.New("this build has no settimeofday (built with CGO_ENABLED=0): use --system-tools, or rebuild with cgo")
+}
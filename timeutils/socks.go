@@ -0,0 +1,63 @@
+package timeutils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// SOCKS5Proxy, when set, tunnels the TCP-based sources (daytime, time protocol
+// over TCP, http, httpjson and tls) through a SOCKS5 proxy such as an SSH
+// dynamic forward. It is host:port, optionally prefixed with user:password@.
+var SOCKS5Proxy string
+
+// tcpDialTimeout bounds connection setup for the TCP-based sources.
+const tcpDialTimeout = 10 * time.Second
+
+// dialTCP connects to addr directly or through SOCKS5Proxy.
+func dialTCP(ctx context.Context, addr string) (net.Conn, error) {
+	direct := &net.Dialer{Timeout: tcpDialTimeout}
+	start := time.Now()
+	if SOCKS5Proxy == "" {
+		conn, err := direct.DialContext(ctx, "tcp", addr)
+		logTrace("tcp dial", "addr", addr, "duration", time.Since(start), "error", err)
+		return conn, err
+	}
+
+	dialer, err := socks5Dialer(direct)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+	logTrace("tcp dial", "addr", addr, "socks5", SOCKS5Proxy, "duration", time.Since(start), "error", err)
+	if err != nil {
+		return nil, fmt.Errorf("socks5 %s: %v", SOCKS5Proxy, err)
+	}
+	return conn, nil
+}
+
+func socks5Dialer(forward proxy.Dialer) (proxy.Dialer, error) {
+	u, err := url.Parse("socks5://" + SOCKS5Proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid SOCKS5 proxy %q", SOCKS5Proxy)
+	}
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: password}
+	}
+	return proxy.SOCKS5("tcp", u.Host, auth, forward)
+}
+
+// ValidateSOCKS5Proxy checks the SOCKS5Proxy setting.
+func ValidateSOCKS5Proxy() error {
+	if SOCKS5Proxy == "" {
+		return nil
+	}
+	_, err := socks5Dialer(proxy.Direct)
+	return err
+}
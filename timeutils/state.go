@@ -0,0 +1,92 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StateDirEnv overrides the directory ntpcl keeps its state in, taking precedence over the configuration.
+const StateDirEnv = "NTPCL_STATE_DIR"
+
+const lastChangeFile = "last-change.json"
+
+// ErrNoLastChange is returned when there is no recorded clock change to undo.
+var ErrNoLastChange = errors.New("no recorded clock change")
+
+// SaveLastChange stores the most recent clock change so it can be undone.
+func SaveLastChange(dir string, entry AuditEntry) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, lastChangeFile), data, 0o600)
+}
+
+// LoadLastChange returns the most recent recorded clock change.
+func LoadLastChange(dir string) (AuditEntry, error) {
+	var entry AuditEntry
+	data, err := os.ReadFile(filepath.Join(dir, lastChangeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return entry, ErrNoLastChange
+	}
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("corrupt state file: %v", err)
+	}
+	return entry, nil
+}
+
+// ClearLastChange forgets the recorded clock change, so it cannot be undone twice.
+func ClearLastChange(dir string) error {
+	err := os.Remove(filepath.Join(dir, lastChangeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+const lastSyncFile = "last-sync.json"
+
+// ErrNoLastSync is returned when the daemon has not recorded a successful query.
+var ErrNoLastSync = errors.New("no successful query recorded")
+
+// SaveLastSync stores the last successful query of the daemon, for the healthcheck command.
+func SaveLastSync(dir string, record LogRecord) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written to a temporary file and renamed, so a probe never reads half of it.
+	path := filepath.Join(dir, lastSyncFile)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// LoadLastSync returns the last successful query recorded by the daemon.
+func LoadLastSync(dir string) (LogRecord, error) {
+	var record LogRecord
+	data, err := os.ReadFile(filepath.Join(dir, lastSyncFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return record, ErrNoLastSync
+	}
+	if err != nil {
+		return record, err
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return record, fmt.Errorf("corrupt state file: %v", err)
+	}
+	return record, nil
+}
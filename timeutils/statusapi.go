@@ -0,0 +1,279 @@
+package timeutils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusHistory is the number of queries the status API keeps for /history:
+// a day at the default interval of 5 minutes.
+const statusHistory = 288
+
+// staleIntervals is how many intervals may pass without a successful query
+// before the time is reported unhealthy.
+const staleIntervals = 3
+
+// SyncRecord is a query in the status API: a log record, plus the error of a
+// failed query.
+type SyncRecord struct {
+	LogRecord
+	Error string `json:"error,omitempty"`
+}
+
+// DaemonStatus answers the /status request of the status API.
+type DaemonStatus struct {
+	Healthy             bool        `json:"healthy"`
+	Reason              string      `json:"reason,omitempty"`
+	StartedAt           time.Time   `json:"started_at"`
+	Interval            float64     `json:"interval_seconds"`
+	Threshold           float64     `json:"offset_threshold_seconds"`
+	ConsecutiveFailures int         `json:"consecutive_failures"`
+	LastSync            *SyncRecord `json:"last_sync,omitempty"`
+	LastError           string      `json:"last_error,omitempty"`
+	// Holdover is set while every source fails and the clock is held over.
+	Holdover *HoldoverStatus `json:"holdover,omitempty"`
+}
+
+// StatusAPI keeps the recent queries of the daemon and serves them as JSON on
+// /status, /last-sync and /history.
+type StatusAPI struct {
+	mu        sync.Mutex
+	started   time.Time
+	interval  time.Duration
+	threshold time.Duration
+	history   []SyncRecord
+	lastSync  *SyncRecord
+	lastError string
+	failures  int
+	holdover  *HoldoverStatus
+}
+
+// NewStatusAPI returns a status API for a daemon querying every interval;
+// offsets beyond threshold are reported unhealthy (0 disables the check).
+func NewStatusAPI(interval, threshold time.Duration) *StatusAPI {
+	return &StatusAPI{started: time.Now(), interval: interval, threshold: threshold}
+}
+
+// Configure updates the interval and threshold, after a config reload.
+func (s *StatusAPI) Configure(interval, threshold time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interval, s.threshold = interval, threshold
+}
+
+// Observe records a successful measurement.
+func (s *StatusAPI) Observe(report Report) {
+	record := SyncRecord{LogRecord: ReportRecord(report)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(record)
+	s.lastSync = &record
+	s.failures = 0
+}
+
+// ObserveFailure records a failed query.
+func (s *StatusAPI) ObserveFailure(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(SyncRecord{LogRecord: LogRecord{Timestamp: time.Now().UTC()}, Error: err.Error()})
+	s.lastError = err.Error()
+	s.failures++
+}
+
+func (s *StatusAPI) add(record SyncRecord) {
+	if len(s.history) == statusHistory {
+		s.history = append(s.history[:0], s.history[1:]...)
+	}
+	s.history = append(s.history, record)
+}
+
+// SetHoldover records the holdover status, nil when synchronized.
+func (s *StatusAPI) SetHoldover(holdover *HoldoverStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.holdover = holdover
+}
+
+// Status returns the health of the time as of now.
+func (s *StatusAPI) Status(now time.Time) DaemonStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := DaemonStatus{
+		StartedAt:           s.started.UTC(),
+		Interval:            s.interval.Seconds(),
+		Threshold:           s.threshold.Seconds(),
+		ConsecutiveFailures: s.failures,
+		LastSync:            s.lastSync,
+		LastError:           s.lastError,
+		Holdover:            s.holdover,
+	}
+	if s.lastSync == nil {
+		status.Reason = ErrNoLastSync.Error()
+	} else if err := CheckHealth(s.lastSync.LogRecord, now, staleIntervals*s.interval, s.threshold); err != nil {
+		status.Reason = err.Error()
+	} else {
+		status.Healthy = true
+	}
+	return status
+}
+
+// CheckHealth returns why the time is unhealthy given the last successful
+// query: it is older than maxAge, or its offset exceeds maxOffset (0
+// disables either check).
+func CheckHealth(last LogRecord, now time.Time, maxAge, maxOffset time.Duration) error {
+	if age := now.Sub(last.Timestamp); maxAge > 0 && age > maxAge {
+		return fmt.Errorf("the last successful query was %v ago, more than %v", age.Round(time.Second), maxAge)
+	}
+	if offset := secondsDuration(last.Offset); maxOffset > 0 && offset.Abs() > maxOffset {
+		return fmt.Errorf("the offset %v exceeds %v", offset, maxOffset)
+	}
+	return nil
+}
+
+// History returns the queries recorded at or after since.
+func (s *StatusAPI) History(since time.Time) []SyncRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := []SyncRecord{}
+	for _, record := range s.history {
+		if !record.Timestamp.Before(since) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// ServeHTTP serves /status (503 when the time is unhealthy), /last-sync,
+// /history?since=, where since is an RFC 3339 time or a duration ago, and
+// /healthz.
+func (s *StatusAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeStatusJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use GET"})
+		return
+	}
+	now := time.Now()
+	switch r.URL.Path {
+	case "/healthz":
+		s.serveHealth(w, now)
+	case "/status":
+		status := s.Status(now)
+		code := http.StatusOK
+		if !status.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		writeStatusJSON(w, code, status)
+	case "/last-sync":
+		status := s.Status(now)
+		if status.LastSync == nil {
+			writeStatusJSON(w, http.StatusNotFound, map[string]string{"error": "no successful query yet"})
+			return
+		}
+		writeStatusJSON(w, http.StatusOK, status.LastSync)
+	case "/history":
+		since, err := parseSince(r.URL.Query().Get("since"), now)
+		if err != nil {
+			writeStatusJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeStatusJSON(w, http.StatusOK, s.History(since))
+	default:
+		writeStatusJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+	}
+}
+
+// HealthHandler serves /healthz alone, for the metrics listener.
+func (s *StatusAPI) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.serveHealth(w, time.Now())
+	})
+}
+
+// serveHealth answers a liveness probe: 200 "ok", or 503 with the reason.
+func (s *StatusAPI) serveHealth(w http.ResponseWriter, now time.Time) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	status := s.Status(now)
+	if !status.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "unhealthy: %s\n", status.Reason)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// parseSince parses the since parameter: an RFC 3339 time or a duration before now.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: use an RFC 3339 time or a duration such as 1h", value)
+}
+
+func writeStatusJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// ListenStatusAPI listens on a loopback address, or on a unix socket given
+// as unix:PATH, replacing a stale socket file. Other addresses are refused,
+// as the API has no authentication.
+func ListenStatusAPI(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := removeStaleSocket(path); err != nil {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return nil, fmt.Errorf("%s is not a loopback address or unix:PATH", addr)
+		}
+	}
+	return net.Listen("tcp", addr)
+}
+
+// removeStaleSocket removes the socket a previous run left at path. Anything
+// else at path is left alone, so a mistyped path cannot delete a file.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
+// ServeStatusAPI serves the status API on a listener from ListenStatusAPI.
+func ServeStatusAPI(listener net.Listener, s *StatusAPI) error {
+	return http.Serve(listener, s)
+}
+
+// secondsDuration converts seconds to a duration.
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
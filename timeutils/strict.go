@@ -0,0 +1,38 @@
+package timeutils
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// StrictMode turns every soft warning about measurement quality into a hard failure.
+var StrictMode bool
+
+// maxRootDispersion is the root dispersion above which a response is considered degraded.
+const maxRootDispersion = 500 * time.Millisecond
+
+// Warnf reports a degradation of the measurement. It prints a warning and
+// returns nil, unless StrictMode is enabled, in which case it returns the
+// warning as an error.
+func Warnf(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if StrictMode {
+		return fmt.Errorf("strict mode: %s", msg)
+	}
+	log.Printf("Warning: %s", msg)
+	return nil
+}
+
+// checkResponseQuality reports validation failures and high dispersion in an NTP response.
+func checkResponseQuality(result *NTPResult) error {
+	if err := result.Validate(); err != nil {
+		if werr := Warnf("NTP response failed validation: %v", err); werr != nil {
+			return werr
+		}
+	}
+	if result.RootDispersion > maxRootDispersion {
+		return Warnf("high root dispersion %v (above %v)", result.RootDispersion, maxRootDispersion)
+	}
+	return nil
+}
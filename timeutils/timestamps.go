@@ -0,0 +1,133 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// NTPVersion is the protocol version sent in NTP queries; 0 uses the ntp package default (4).
+var NTPVersion int
+
+// NTPTimestamps holds the four timestamps of an NTP exchange along with the values derived from them.
+type NTPTimestamps struct {
+	T1        time.Time     // client transmit (origin)
+	T2        time.Time     // server receive
+	T3        time.Time     // server transmit
+	T4        time.Time     // client receive (destination)
+	Reference time.Time     // server reference time
+	Theta     time.Duration // offset: ((T2 - T1) + (T3 - T4)) / 2
+	Delta     time.Duration // round trip delay: (T4 - T1) - (T3 - T2)
+}
+
+// NTPResult is an NTP response together with the timestamps of the exchange that produced it.
+type NTPResult struct {
+	*ntp.Response
+	Timestamps NTPTimestamps
+}
+
+// receiveTimeCapture is an ntp.Extension that records the client transmit
+// (T1) and receive (T4) times and the server receive timestamp (T2), which the
+// ntp package does not expose, and the raw response for Recording.
+type receiveTimeCapture struct {
+	transmitTime time.Time
+	receiveTime  time.Time
+	arrivalTime  time.Time
+	packet       []byte
+}
+
+// ProcessQuery runs right before the query is written, so it takes T1. T1 and
+// T4 are wall clock readings, as NTP timestamps are: without the monotonic
+// reading, a step of the clock during the exchange shows in them.
+func (c *receiveTimeCapture) ProcessQuery(_ *bytes.Buffer) error {
+	c.transmitTime = time.Now().Round(0)
+	return nil
+}
+
+// ProcessResponse runs right after the response is read, so it takes T4.
+func (c *receiveTimeCapture) ProcessResponse(buf []byte) error {
+	c.arrivalTime = time.Now().Round(0)
+	if len(buf) < 48 {
+		return fmt.Errorf("short NTP response: %d bytes", len(buf))
+	}
+	c.receiveTime = ntpTimestampToTime(binary.BigEndian.Uint64(buf[32:40]))
+	if Recording != nil {
+		c.packet = bytes.Clone(buf)
+	}
+	return nil
+}
+
+// ntpTimestampToTime converts a 64-bit NTP timestamp to a time.Time.
+func ntpTimestampToTime(ts uint64) time.Time {
+	seconds := int64(ts>>32) - ntpEpochOffset
+	nanoseconds := (int64(ts&0xffffffff) * 1e9) >> 32
+	return time.Unix(seconds, nanoseconds).UTC()
+}
+
+// newNTPTimestamps takes the four timestamps of the exchange and derives
+// theta = ((T2 - T1) + (T3 - T4)) / 2 and delta = (T4 - T1) - (T3 - T2) from
+// them. The offset, round trip and the values that depend on them in response
+// are replaced by these, so that the response agrees with its timestamps.
+func newNTPTimestamps(response *ntp.Response, capture *receiveTimeCapture) NTPTimestamps {
+	t1, t2, t3, t4 := capture.transmitTime, capture.receiveTime, response.Time.UTC(), capture.arrivalTime
+	theta := (t2.Sub(t1) + t3.Sub(t4)) / 2
+	delta := max(t4.Sub(t1)-t3.Sub(t2), 0)
+	response.ClockOffset = theta
+	response.RTT = delta
+	response.RootDistance = (delta+response.RootDelay)/2 + response.RootDispersion
+	response.MinError = max(t1.Sub(t2), t3.Sub(t4), 0)
+	return NTPTimestamps{
+		T1:        t1,
+		T2:        t2,
+		T3:        t3,
+		T4:        t4,
+		Reference: response.ReferenceTime.UTC(),
+		Theta:     theta,
+		Delta:     delta,
+	}
+}
+
+// queryNTPWithTimestamps queries an NTP server and returns the response with its exchange timestamps.
+func queryNTPWithTimestamps(server string) (*NTPResult, error) {
+	if Replaying != nil {
+		return replayNTP(server)
+	}
+	capture := &receiveTimeCapture{}
+	response, err := ntp.QueryWithOptions(server, ntpQueryOptions(server, capture))
+	if err != nil {
+		recordNTP(server, capture, err)
+		return nil, err
+	}
+	result := &NTPResult{
+		Response:   response,
+		Timestamps: newNTPTimestamps(response, capture),
+	}
+	Logger.Debug("NTP response", "server", server, "stratum", response.Stratum, "offset", response.ClockOffset,
+		"rtt", response.RTT, "root_dispersion", response.RootDispersion)
+	recordNTP(server, capture, nil)
+	return result, nil
+}
+
+// ntpQueryOptions returns the query options for server, with the wire dump
+// extension added when DebugWire is set, the TTL and DSCP marking applied and
+// the packets captured when Capture is set.
+func ntpQueryOptions(server string, extensions ...ntp.Extension) ntp.QueryOptions {
+	if DebugWire != nil {
+		extensions = append(extensions, ntpWireDump{server: server})
+	}
+	opts := ntp.QueryOptions{Version: NTPVersion, Extensions: extensions}
+	if PacketTTL != 0 || PacketDSCP != 0 {
+		opts.Dialer = qosDialer
+	}
+	if Capture != nil {
+		// Without marking, qosDialer dials as the ntp package does.
+		opts.Dialer = Capture.dialer(qosDialer)
+	}
+	return opts
+}
@@ -2,13 +2,15 @@
 package timeutils
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
 	"sort"
@@ -17,8 +19,6 @@ import (
 	"time"
 
 	"github.com/beevik/ntp"
-	"github.com/fatih/color"
-	"github.com/olekukonko/tablewriter"
 )
 
 type sampleResult struct {
@@ -27,27 +27,54 @@ type sampleResult struct {
 	timestamp time.Time
 }
 
-// FetchTimeFromDaytimeProtocol fetches the time from a server using the Daytime Protocol (RFC 867).
-func FetchTimeFromDaytimeProtocol(server string) (time.Time, time.Duration, error) {
+// FetchTimeFromDaytimeProtocol fetches the time from a server using the Daytime Protocol (RFC 867),
+// over TCP or, with DaytimeOptions.UDP, over UDP.
+func FetchTimeFromDaytimeProtocol(server string, daytimeOpts DaytimeOptions) (time.Time, time.Duration, error) {
+	addr := net.JoinHostPort(server, "13")
 	start := time.Now()
-	conn, err := net.Dial("tcp", net.JoinHostPort(server, "13"))
+
+	var conn net.Conn
+	var err error
+	if daytimeOpts.UDP {
+		conn, err = net.Dial("udp", addr)
+	} else {
+		conn, err = dialTCP(context.Background(), addr)
+	}
 	if err != nil {
 		return time.Time{}, 0, err
 	}
 	defer conn.Close()
 
-	reader := bufio.NewReader(conn)
-	response, err := reader.ReadString('\n')
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	var data []byte
+	if daytimeOpts.UDP {
+		// Over UDP the server answers an empty datagram with a single datagram.
+		if _, err := conn.Write(nil); err != nil {
+			return time.Time{}, 0, err
+		}
+		dumpWire(">", "daytime/udp", addr, nil)
+		buffer := make([]byte, 1024)
+		var n int
+		n, err = conn.Read(buffer)
+		data = buffer[:n]
+	} else {
+		// The server closes the connection after the response, which may start with an empty line.
+		data, err = io.ReadAll(io.LimitReader(conn, 1024))
+	}
 	if err != nil {
 		return time.Time{}, 0, err
 	}
+	dumpWire("<", "daytime", addr, data)
+	response := string(data)
 
 	rtt := time.Since(start)
 
-	// Debug: Print raw Daytime response
-	fmt.Printf("Raw Daytime response: %s\n", response)
+	Logger.Debug("daytime response", "server", addr, "response", strings.TrimSpace(response), "rtt", rtt)
 
-	serverTime, err := parseDaytimeResponse(response)
+	serverTime, err := parseDaytimeResponse(response, daytimeOpts)
 	if err != nil {
 		return time.Time{}, 0, err
 	}
@@ -56,32 +83,50 @@ func FetchTimeFromDaytimeProtocol(server string) (time.Time, time.Duration, erro
 }
 
 // parseDaytimeResponse parses the response from the Daytime Protocol to extract the time.
-func parseDaytimeResponse(response string) (time.Time, error) {
-	response = strings.TrimSpace(response)
-	serverTime, err := time.Parse("Mon Jan 2 15:04:05 2006", response)
-	if err != nil {
-		return time.Time{}, err
-	}
-	return serverTime, nil
+func parseDaytimeResponse(response string, daytimeOpts DaytimeOptions) (time.Time, error) {
+	return daytimeOpts.parse(daytimeLine(response))
 }
 
-// FetchTimeFromTimeProtocol fetches the time from a server using the Time Protocol (RFC 868).
-func FetchTimeFromTimeProtocol(server string) (time.Time, time.Duration, error) {
+// FetchTimeFromTimeProtocol fetches the time from a server using the Time Protocol (RFC 868),
+// over UDP or, with useTCP, over TCP.
+func FetchTimeFromTimeProtocol(server string, useTCP bool) (time.Time, time.Duration, error) {
+	addr := net.JoinHostPort(server, "37")
 	start := time.Now()
-	conn, err := net.Dial("udp", net.JoinHostPort(server, "37"))
+
+	var conn net.Conn
+	var err error
+	if useTCP {
+		conn, err = dialTCP(context.Background(), addr)
+	} else {
+		conn, err = net.Dial("udp", addr)
+	}
 	if err != nil {
 		return time.Time{}, 0, err
 	}
 	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return time.Time{}, 0, err
+	}
 
 	buffer := make([]byte, 4)
-	n, err := conn.Read(buffer)
+	if useTCP {
+		// The server sends the time as soon as the connection is open.
+		_, err = io.ReadFull(conn, buffer)
+	} else {
+		// Over UDP the server answers an empty datagram.
+		if _, err := conn.Write(nil); err != nil {
+			return time.Time{}, 0, err
+		}
+		dumpWire(">", "time/udp", addr, nil)
+		var n int
+		if n, err = conn.Read(buffer); err == nil && n != 4 {
+			err = fmt.Errorf("invalid response size")
+		}
+	}
 	if err != nil {
 		return time.Time{}, 0, err
 	}
-	if n != 4 {
-		return time.Time{}, 0, fmt.Errorf("invalid response size")
-	}
+	dumpWire("<", "time", addr, buffer)
 
 	rtt := time.Since(start)
 
@@ -93,31 +138,60 @@ func FetchTimeFromTimeProtocol(server string) (time.Time, time.Duration, error)
 	return serverTime, rtt, nil
 }
 
-// FetchTimeFromHTTP fetches the time from an HTTP server's Date header.
-func FetchTimeFromHTTP(url string) (time.Time, time.Duration, error) {
-	start := time.Now()
-	resp, err := http.Head(url)
+// FetchTimeFromHTTP fetches the time from an HTTP server's Date header. With
+// the auto method it sends HEAD and falls back to GET when the server rejects
+// HEAD or leaves out the Date header. It also returns the URLs it was redirected
+// to; the time and round trip are those of the last one.
+func FetchTimeFromHTTP(url string, httpOpts HTTPOptions) (time.Time, time.Duration, []string, error) {
+	methods, err := httpOpts.methods()
 	if err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, nil, err
 	}
-	rtt := time.Since(start)
+
+	client := httpOpts.client()
+	for i, method := range methods {
+		serverTime, rtt, redirects, err := fetchHTTPDate(httpOpts, client, method, url)
+		if err == nil || i == len(methods)-1 {
+			return serverTime, rtt, redirects, err
+		}
+		Logger.Info("retrying with the next HTTP method", "url", url, "method", method, "next", methods[i+1], "error", err)
+	}
+	return time.Time{}, 0, nil, fmt.Errorf("no HTTP method to try")
+}
+
+// fetchHTTPDate sends a single request and parses the Date header of the response.
+func fetchHTTPDate(httpOpts HTTPOptions, client *http.Client, method, url string) (time.Time, time.Duration, []string, error) {
+	req, err := httpOpts.newRequest(method, url)
+	if err != nil {
+		return time.Time{}, 0, nil, err
+	}
+
+	resp, rtt, redirects, err := httpOpts.do(client, req)
+	if err != nil {
+		return time.Time{}, 0, redirects, err
+	}
+	// Only the headers are needed; closing the body leaves a GET response unread.
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		return time.Time{}, 0, redirects, fmt.Errorf("%s rejected with %s", method, resp.Status)
+	}
+
 	dateHeader := resp.Header.Get("Date")
 	if dateHeader == "" {
-		return time.Time{}, 0, fmt.Errorf("no Date header found in response")
+		return time.Time{}, 0, redirects, fmt.Errorf("no Date header found in response")
 	}
 
 	serverTime, err := time.Parse(time.RFC1123, dateHeader)
 	if err != nil {
-		return time.Time{}, 0, err
+		return time.Time{}, 0, redirects, err
 	}
 
-	return serverTime, rtt, nil
+	return serverTime, rtt, redirects, nil
 }
 
 // FetchTimeFromNTP fetches the time from an NTP server.
-func FetchTimeFromNTP(ntpServer, windowsTimeServer string, highAccuracy bool) (time.Time, time.Duration, *ntp.Response, string, error) {
+func FetchTimeFromNTP(ntpServer, windowsTimeServer string, highAccuracy bool) (time.Time, time.Duration, *NTPResult, string, error) {
 	var serverToUse string
 	if windowsTimeServer != "" {
 		serverToUse = windowsTimeServer
@@ -134,6 +208,7 @@ func FetchTimeFromNTP(ntpServer, windowsTimeServer string, highAccuracy bool) (t
 		}
 		serverToUse = ip
 	}
+	Logger.Info("querying NTP server", "server", serverToUse, "high_accuracy", highAccuracy)
 
 	if highAccuracy {
 		serverTime, err := GatherHighAccuracyTime(serverToUse)
@@ -144,22 +219,31 @@ func FetchTimeFromNTP(ntpServer, windowsTimeServer string, highAccuracy bool) (t
 		return serverTime, 0, nil, serverToUse, nil
 	}
 
-	response, err := ntp.Query(serverToUse)
+	result, err := queryNTPWithTimestamps(serverToUse)
 	if err != nil {
 		return time.Time{}, 0, nil, "", err
 	}
+	if err := ValidateResponse(result); err != nil {
+		return time.Time{}, 0, nil, "", &RejectedError{Server: serverToUse, Err: err}
+	}
+	if err := checkResponseQuality(result); err != nil {
+		return time.Time{}, 0, nil, "", err
+	}
 
-	serverTime := time.Now().Add(response.ClockOffset)
+	serverTime := Now().Add(result.ClockOffset)
 
-	return serverTime, response.RTT, response, serverToUse, nil
+	return serverTime, result.RTT, result, serverToUse, nil
 }
 
 // GatherHighAccuracyTime gathers multiple samples to get a high accuracy time.
 func GatherHighAccuracyTime(ntpServerToUse string) (time.Time, error) {
-	fmt.Println("High accuracy mode enabled. Gathering multiple samples in parallel...")
+	Logger.Info("gathering samples in parallel", "server", ntpServerToUse)
 
+	// At least minSampleCount good samples are needed outside strict mode,
+	// enough for the median 60% to rest on more than a couple of them.
 	const (
 		sampleCount    = 10
+		minSampleCount = 8
 		timeoutSeconds = 5
 	)
 
@@ -168,6 +252,10 @@ func GatherHighAccuracyTime(ntpServerToUse string) (time.Time, error) {
 
 	var wg sync.WaitGroup
 	results := make(chan sampleResult, sampleCount)
+	var kissOnce sync.Once
+	var kissErr error
+	var rejectOnce sync.Once
+	var rejectErr error
 
 	for i := 0; i < sampleCount; i++ {
 		wg.Add(1)
@@ -179,13 +267,39 @@ func GatherHighAccuracyTime(ntpServerToUse string) (time.Time, error) {
 					return
 				default:
 					start := time.Now()
-					resp, err := ntp.Query(ntpServerToUse)
+					resp, err := queryNTPWithTimestamps(ntpServerToUse)
 					if err != nil {
-						fmt.Printf("Sample query failed: %v. Retrying...\n", err)
+						if werr := Warnf("sample query failed: %v", err); werr != nil {
+							return
+						}
+						Logger.Debug("retrying sample query", "server", ntpServerToUse, "error", err)
 						time.Sleep(100 * time.Millisecond)
 						continue
 					}
+					// Samples face the same checks as a single query: kiss-of-death,
+					// invalid strata, LI=3, a zero reference timestamp, non-monotonic
+					// T1-T4, --max-stratum and --max-root-distance.
+					if err := ValidateResponse(resp); err != nil {
+						var kod *KissOfDeathError
+						if errors.As(err, &kod) {
+							// Stop querying a server that asks us to go away.
+							kissOnce.Do(func() {
+								kissErr = kod
+								cancel()
+							})
+							return
+						}
+						err = &RejectedError{Server: ntpServerToUse, Err: err}
+						rejectOnce.Do(func() { rejectErr = err })
+						// In strict mode the rejection itself is returned below.
+						_ = Warnf("dropped sample: %v", err)
+						return
+					}
+					if err := checkResponseQuality(resp); err != nil {
+						return
+					}
 					rtt := time.Since(start)
+					logTrace("sample", "server", ntpServerToUse, "offset", resp.ClockOffset, "rtt", rtt)
 					results <- sampleResult{
 						offset:    resp.ClockOffset,
 						rtt:       rtt,
@@ -206,10 +320,21 @@ func GatherHighAccuracyTime(ntpServerToUse string) (time.Time, error) {
 	for result := range results {
 		samples = append(samples, result)
 	}
+	if kissErr != nil {
+		return time.Time{}, kissErr
+	}
 
-	if len(samples) < sampleCount {
+	if rejectErr != nil && (len(samples) < minSampleCount || StrictMode) {
+		return time.Time{}, rejectErr
+	}
+	if len(samples) < minSampleCount {
 		return time.Time{}, fmt.Errorf("failed to gather enough samples, got %d out of %d", len(samples), sampleCount)
 	}
+	if len(samples) < sampleCount {
+		if err := Warnf("only gathered %d out of %d samples", len(samples), sampleCount); err != nil {
+			return time.Time{}, err
+		}
+	}
 
 	// Sort samples by RTT
 	sort.Slice(samples, func(i, j int) bool {
@@ -217,7 +342,9 @@ func GatherHighAccuracyTime(ntpServerToUse string) (time.Time, error) {
 	})
 
 	// Use the median 60% of samples
-	validSamples := samples[sampleCount/5 : 4*sampleCount/5]
+	validSamples := samples[len(samples)/5 : 4*len(samples)/5]
+	Logger.Debug("selected samples by RTT", "gathered", len(samples), "used", len(validSamples),
+		"min_rtt", validSamples[0].rtt, "max_rtt", validSamples[len(validSamples)-1].rtt)
 
 	var totalOffset time.Duration
 	var totalRTT time.Duration
@@ -240,63 +367,235 @@ func GatherHighAccuracyTime(ntpServerToUse string) (time.Time, error) {
 	// Adjust the final time calculation
 	adjustedTime := time.Now().Add(averageOffset).Add(-elapsedSinceLastSample)
 
-	fmt.Printf("Average offset: %v\n", averageOffset)
-	fmt.Printf("Average RTT: %v\n", averageRTT)
-	fmt.Printf("Elapsed since last sample: %v\n", elapsedSinceLastSample)
-	fmt.Printf("Adjusted time: %v\n", adjustedTime)
+	Logger.Info("high accuracy result", "average_offset", averageOffset, "average_rtt", averageRTT,
+		"since_last_sample", elapsedSinceLastSample, "adjusted_time", adjustedTime)
 
 	return adjustedTime, nil
 }
 
-// SetSystemTimeWrapper decides whether to use system calls or system commands.
-func SetSystemTimeWrapper(t time.Time, useSystemTools bool) error {
-	if useSystemTools {
+// SetSystemTimeWrapper sets the system time with the given set method: system
+// calls, system commands, chronyd or the Windows Time service.
+func SetSystemTimeWrapper(t time.Time, method string) error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+	switch method {
+	case SetMethodCommand:
 		return SetSystemTimeWithCommand(t)
+	case SetMethodChrony:
+		_, err := SetTimeViaChrony(t)
+		return err
+	case SetMethodW32Time:
+		return W32TimeResync()
+	default:
+		return SetSystemTime(t)
 	}
-	return SetSystemTime(t)
 }
 
 // SetSystemTimeWithCommand sets the system time using system commands.
 func SetSystemTimeWithCommand(t time.Time) error {
-	var cmd *exec.Cmd
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+
+	// BSD, illumos and toybox date take whole seconds: wait until t reaches
+	// the next one, so that the fraction is not dropped from the time set.
+	if SetPrecision(SetMethodCommand) == time.Second {
+		next := t.Truncate(time.Second).Add(time.Second)
+		time.Sleep(next.Sub(t))
+		t = next
+	}
+	commands, err := systemTimeCommands(t)
+	if err != nil {
+		return err
+	}
+	for _, args := range commands {
+		if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+			if text := strings.TrimSpace(string(output)); text != "" {
+				return fmt.Errorf("%s: %v: %s", strings.Join(args, " "), err, text)
+			}
+			return fmt.Errorf("%s: %v", strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
+
+// systemTimeCommands returns the commands that set the system time to t on this platform.
+func systemTimeCommands(t time.Time) ([][]string, error) {
 	formattedTime := t.Format("2006-01-02 15:04:05.000000000")
 
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("cmd", "/C", "date", formattedTime[:10])
-		if err := cmd.Run(); err != nil {
-			return err
-		}
-		cmd = exec.Command("cmd", "/C", "time", formattedTime[11:])
+		// Set-Date -Adjust shifts the clock by the offset in one call, leaving
+		// the time zone to Windows and unaffected by PowerShell's slow start,
+		// unlike cmd's date and time, which parse a locale-dependent local time.
+		ticks := time.Until(t) / 100 // TimeSpan ticks are 100ns
+		return [][]string{{"powershell", "-NoProfile", "-NonInteractive", "-Command",
+			fmt.Sprintf("Set-Date -Adjust ([TimeSpan]::FromTicks(%d)) | Out-Null", ticks)}}, nil
 	case "linux":
-		cmd = exec.Command("sudo", "date", "-s", formattedTime)
+		if usesTimedated() {
+			// timedated is authorized through polkit, so escalation is not needed.
+			return [][]string{{"timedatectl", "set-time", t.Local().Format("2006-01-02 15:04:05.000000")}}, nil
+		}
+		return [][]string{escalated("date", "-s", formattedTime)}, nil
 	case "darwin":
-		cmd = exec.Command("sudo", "date", "-u", formattedTime)
+		return [][]string{escalated("date", "-u", formattedTime)}, nil
+	case "android":
+		// toybox date takes MMDDhhmm[[CC]YY][.ss]; su only takes a single command string.
+		command := "date -u " + t.UTC().Format("010215042006.05")
+		if os.Geteuid() == 0 {
+			return [][]string{strings.Fields(command)}, nil
+		}
+		return [][]string{{"su", "-c", command}}, nil
+	case "freebsd", "openbsd", "netbsd":
+		// BSD date takes [[[[[cc]yy]mm]dd]HH]MM[.ss].
+		return [][]string{escalated("date", "-u", t.UTC().Format("200601021504.05"))}, nil
+	case "solaris", "illumos":
+		// illumos and Solaris date take mmddHHMM[[cc]yy][.SS].
+		return [][]string{escalated("date", "-u", t.UTC().Format("010215042006.05"))}, nil
 	default:
-		return fmt.Errorf("unsupported platform")
+		return nil, fmt.Errorf("unsupported platform")
+	}
+}
+
+// usesTimedated reports whether the system was booted with systemd and has
+// timedatectl, in which case the clock is set through systemd-timedated.
+func usesTimedated() bool {
+	if _, err := os.Stat("/run/systemd/system"); err != nil {
+		return false
 	}
+	_, err := exec.LookPath("timedatectl")
+	return err == nil
+}
 
-	return cmd.Run()
+// SetPrecision returns the resolution of the time a set method applies, or 0
+// when it is up to a time daemon.
+func SetPrecision(method string) time.Duration {
+	switch method {
+	case SetMethodChrony, SetMethodW32Time:
+		return 0
+	case SetMethodCommand:
+		switch runtime.GOOS {
+		case "linux":
+			if usesTimedated() {
+				return time.Microsecond
+			}
+			return time.Nanosecond
+		case "windows":
+			return time.Millisecond // Set-Date ends in SetLocalTime, which takes milliseconds
+		default:
+			return time.Second // BSD, illumos and toybox date take whole seconds
+		}
+	default:
+		return syscallSetPrecision
+	}
 }
 
-// GetServerIP resolves the IP address of the server.
+// DescribeSetMethod describes how the system time would be set to t, for dry runs.
+func DescribeSetMethod(t time.Time, method string) string {
+	switch method {
+	case SetMethodCommand:
+	case SetMethodChrony:
+		return "chronyd settime/makestep (" + ChronySocket + ")"
+	case SetMethodW32Time:
+		return "`" + strings.Join(W32TimeResyncCommand, " ") + "`"
+	default:
+		return syscallSetMechanism
+	}
+
+	commands, err := systemTimeCommands(t)
+	if err != nil {
+		return err.Error()
+	}
+	var described []string
+	for _, args := range commands {
+		described = append(described, "`"+strings.Join(args, " ")+"`")
+	}
+	return strings.Join(described, " and ")
+}
+
+// GetServerIP resolves the IP address of the server: its first IPv4 address,
+// or its first IPv6 address when it has none.
 func GetServerIP(server string) (string, error) {
-	ips, err := net.LookupIP(server)
+	addresses, err := ResolveServer(server)
 	if err != nil {
 		return "", err
 	}
+	Logger.Info("selected address", "server", server, "address", addresses[0])
+	return addresses[0], nil
+}
+
+// ResolveServer resolves every address of the server, IPv4 before IPv6, or
+// takes them from the session when replaying.
+func ResolveServer(server string) ([]string, error) {
+	if Replaying != nil {
+		e, err := Replaying.Next(ExchangeResolve, server)
+		if err != nil {
+			return nil, err
+		}
+		return e.Addresses, e.Err()
+	}
+	addresses, err := lookupServerAddresses(server)
+	if Recording != nil {
+		e := Exchange{Kind: ExchangeResolve, Server: server, Addresses: addresses, LocalTime: time.Now()}
+		if err != nil {
+			e.Error = err.Error()
+		}
+		Recording.Record(e)
+	}
+	return addresses, err
+}
+
+func lookupServerAddresses(server string) ([]string, error) {
+	start := time.Now()
+	ips, err := net.LookupIP(server)
+	Logger.Debug("resolved server", "server", server, "addresses", ips, "duration", time.Since(start), "error", err)
+	if err != nil {
+		return nil, err
+	}
+	var ipv4, ipv6 []string
 	for _, ip := range ips {
-		if ipv4 := ip.To4(); ipv4 != nil {
-			return ipv4.String(), nil
+		if v4 := ip.To4(); v4 != nil {
+			ipv4 = append(ipv4, v4.String())
+		} else {
+			ipv6 = append(ipv6, ip.String())
+		}
+	}
+	if len(ipv4)+len(ipv6) == 0 {
+		return nil, fmt.Errorf("no IP address found for server %s", server)
+	}
+	return append(ipv4, ipv6...), nil
+}
+
+// reverseLookupTimeout bounds the PTR lookup, which only serves to identify the server.
+const reverseLookupTimeout = 2 * time.Second
+
+// LookupReverse returns the PTR name of an address without the trailing dot,
+// or "" without one. It is taken from the session when replaying.
+func LookupReverse(address string) string {
+	if Replaying != nil {
+		e, err := Replaying.Next(ExchangeReverse, address)
+		if err != nil {
+			Logger.Debug("no reverse lookup to replay", "address", address, "error", err)
 		}
+		return e.Name
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reverseLookupTimeout)
+	defer cancel()
+	names, err := net.DefaultResolver.LookupAddr(ctx, address)
+	Logger.Debug("reverse lookup", "address", address, "names", names, "error", err)
+	var name string
+	if err == nil && len(names) > 0 {
+		name = strings.TrimSuffix(names[0], ".")
 	}
-	return "", fmt.Errorf("no IPv4 address found for server %s", server)
+	Recording.Record(Exchange{Kind: ExchangeReverse, Server: address, Name: name, LocalTime: time.Now()})
+	return name
 }
 
 // QueryNTPTime queries the NTP server for the current time.
 func QueryNTPTime(server string) (*ntp.Response, time.Duration, error) {
 	start := time.Now()
-	response, err := ntp.Query(server)
+	response, err := ntp.QueryWithOptions(server, ntpQueryOptions(server))
 	if err != nil {
 		return nil, 0, err
 	}
@@ -311,7 +610,7 @@ func QuerySNTPTime(server string) (*ntp.Response, time.Duration, error) {
 
 // DisplayTimeInfo displays the fetched time and round trip time
 func DisplayTimeInfo(method string, serverTime time.Time, roundTripTime time.Duration, server string, ntpResponse *ntp.Response) {
-	localTime := time.Now()
+	localTime := Now()
 	timeDiff := serverTime.Sub(localTime)
 
 	fmt.Print(FormattedOutput(method, serverTime, localTime, timeDiff, roundTripTime, server, ntpResponse))
@@ -325,42 +624,97 @@ func PrintNTPDetails(method string, serverTime time.Time, rtt time.Duration, ser
 	fmt.Print(FormattedOutput(method, serverTime, localTime, timeDiff, rtt, fmt.Sprintf("%s (%s)", server, serverIP), response))
 }
 
+// FormatEpoch formats t as a Unix timestamp in seconds ("s"), milliseconds ("ms")
+// or nanoseconds ("ns").
+func FormatEpoch(t time.Time, unit string) (string, error) {
+	switch unit {
+	case "s":
+		return fmt.Sprintf("%d", t.Unix()), nil
+	case "ms":
+		return fmt.Sprintf("%d", t.UnixMilli()), nil
+	case "ns":
+		return fmt.Sprintf("%d", t.UnixNano()), nil
+	default:
+		return "", fmt.Errorf("unknown unit %q (use s, ms or ns)", unit)
+	}
+}
+
+// PlainOutput makes FormattedOutput render "Property: value" lines instead of
+// a table, for narrow terminals and serial consoles.
+var PlainOutput bool
+
+// DisplayZones are the time zones FormattedOutput renders the server and local
+// times in. When empty, the server time is shown as received and the local time
+// in the local zone.
+var DisplayZones []*time.Location
+
+// ParseTimeZones parses a comma-separated list of IANA zone names, "UTC" and "local".
+func ParseTimeZones(spec string) ([]*time.Location, error) {
+	var zones []*time.Location
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if strings.EqualFold(name, "local") {
+			zones = append(zones, time.Local)
+			continue
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, loc)
+	}
+	return zones, nil
+}
+
 // FormattedOutput generates a formatted string for displaying time information
 func FormattedOutput(method string, serverTime, localTime time.Time, timeDiff, rtt time.Duration, server string, ntpResponse *ntp.Response) string {
-	var buf bytes.Buffer
-	table := tablewriter.NewWriter(&buf)
-	table.SetHeader([]string{"Property", "Value"})
-	table.SetAlignment(tablewriter.ALIGN_LEFT)
-	table.SetBorder(false)
+	var rows [][]string
 
 	addRow := func(property, value string) {
-		table.Append([]string{property, value})
+		rows = append(rows, []string{property, value})
 	}
 
 	addColoredRow := func(property, value string, duration time.Duration) {
 		coloredValue := value
 		switch {
+		case !colorEnabled():
 		case duration.Abs() < 250*time.Millisecond:
-			coloredValue = color.GreenString(value)
+			coloredValue = green(value)
 		case duration.Abs() < 1*time.Second:
-			coloredValue = color.YellowString(value)
+			coloredValue = yellow(value)
 		default:
-			coloredValue = color.RedString(value)
+			coloredValue = red(value)
 		}
-		table.Append([]string{property, coloredValue})
+		rows = append(rows, []string{property, coloredValue})
 	}
 
 	addRow("Method", method)
-	addRow("Server Time", serverTime.Format(time.RFC3339Nano))
-	addRow("Local Time", localTime.Format(time.RFC3339Nano))
+	switch len(DisplayZones) {
+	case 0:
+		addRow("Server Time", serverTime.Format(time.RFC3339Nano))
+		addRow("Local Time", localTime.Format(time.RFC3339Nano))
+	case 1:
+		addRow("Server Time", serverTime.In(DisplayZones[0]).Format(time.RFC3339Nano))
+		addRow("Local Time", localTime.In(DisplayZones[0]).Format(time.RFC3339Nano))
+	default:
+		for _, zone := range DisplayZones {
+			addRow("Server Time ("+zone.String()+")", serverTime.In(zone).Format(time.RFC3339Nano))
+		}
+		for _, zone := range DisplayZones {
+			addRow("Local Time ("+zone.String()+")", localTime.In(zone).Format(time.RFC3339Nano))
+		}
+	}
 	addColoredRow("Time Difference", timeDiff.String(), timeDiff)
 	addRow("Round Trip Time", rtt.String())
 	if server != "" {
 		addRow("Server", server)
 	}
+	addRow("TAI-UTC", Leaps.TAIOffset(serverTime).String())
 
 	if ntpResponse != nil {
 		addRow("Stratum", fmt.Sprintf("%d", ntpResponse.Stratum))
+		addRow("Reference ID", DescribeReferenceID(ntpResponse))
+		addRow("Leap Indicator", DescribeLeap(ntpResponse.Leap))
 		addRow("Precision", fmt.Sprintf("%d", ntpResponse.Precision))
 		addRow("Root Delay", ntpResponse.RootDelay.String())
 		addRow("Root Dispersion", ntpResponse.RootDispersion.String())
@@ -368,6 +722,14 @@ func FormattedOutput(method string, serverTime, localTime time.Time, timeDiff, r
 		addRow("Poll Interval", ntpResponse.Poll.String())
 	}
 
-	table.Render()
+	var buf bytes.Buffer
+	if PlainOutput {
+		for _, row := range rows {
+			fmt.Fprintf(&buf, "%s: %s\n", row[0], row[1])
+		}
+		return buf.String()
+	}
+
+	renderTable(&buf, []string{"Property", "Value"}, rows, tableStyle{})
 	return buf.String()
 }
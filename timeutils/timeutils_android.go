@@ -0,0 +1,42 @@
+//go:build android
+// +build android
+
+package timeutils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// androidDefaults returns the built-in defaults for Android, which builds the
+// Linux files too. Root sets the clock directly; otherwise date runs through
+// su. The state directory is in Termux when running there.
+func androidDefaults() PlatformDefaults {
+	defaults := PlatformDefaults{
+		Server:    "2.android.pool.ntp.org",
+		SetMethod: SetMethodSyscall,
+		Service:   "none",
+		StateDir:  "/data/local/tmp/ntpcl",
+	}
+	if os.Geteuid() != 0 {
+		defaults.SetMethod = SetMethodCommand
+	}
+	if prefix := os.Getenv("PREFIX"); prefix != "" && os.Getenv("TERMUX_VERSION") != "" {
+		defaults.Service = "termux-services"
+		defaults.StateDir = filepath.Join(prefix, "var", "lib", "ntpcl")
+	}
+	return defaults
+}
+
+// checkSu reports whether the command method can escalate through su, which
+// only rooted devices have.
+func checkSu() error {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath("su"); err != nil {
+		return ErrNotRooted
+	}
+	return nil
+}
@@ -8,8 +8,20 @@ import (
 	"time"
 )
 
+// syscallSetMechanism names the system call SetSystemTime uses, for dry runs.
+// clock_settime(CLOCK_REALTIME) in the macOS libc is built on settimeofday
+// too, so it would not keep more than microseconds either.
+const syscallSetMechanism = "settimeofday"
+
+// syscallSetPrecision is the resolution of the time SetSystemTime applies.
+const syscallSetPrecision = time.Microsecond
+
 // SetSystemTime sets the system time on macOS using the Darwin syscall.
 func SetSystemTime(t time.Time) error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+
 	utc := t.UTC()
 	tv := syscall.Timeval{
 		Sec:  utc.Unix(),
@@ -18,3 +30,13 @@ func SetSystemTime(t time.Time) error {
 
 	return syscall.Settimeofday(&tv)
 }
+
+// platformDefaults returns the built-in defaults for macOS.
+func platformDefaults() PlatformDefaults {
+	return PlatformDefaults{
+		Server:    "time.apple.com",
+		SetMethod: SetMethodSyscall,
+		Service:   "launchd",
+		StateDir:  "/Library/Application Support/ntpcl",
+	}
+}
@@ -0,0 +1,36 @@
+//go:build freebsd
+// +build freebsd
+
+package timeutils
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// syscallSetMechanism names the system call SetSystemTime uses, for dry runs.
+const syscallSetMechanism = "settimeofday"
+
+// syscallSetPrecision is the resolution of the time SetSystemTime applies.
+const syscallSetPrecision = time.Microsecond
+
+// SetSystemTime sets the system time on FreeBSD using settimeofday.
+func SetSystemTime(t time.Time) error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+
+	tv := unix.NsecToTimeval(t.UnixNano())
+	return unix.Settimeofday(&tv)
+}
+
+// platformDefaults returns the built-in defaults for FreeBSD.
+func platformDefaults() PlatformDefaults {
+	return PlatformDefaults{
+		Server:    "0.freebsd.pool.ntp.org",
+		SetMethod: SetMethodSyscall,
+		Service:   "rc.d",
+		StateDir:  "/var/db/ntpcl",
+	}
+}
@@ -4,15 +4,37 @@
 package timeutils
 
 import (
-	"syscall"
+	"runtime"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
-// SetSystemTime sets the system time on Linux using syscalls.
+// syscallSetMechanism names the system call SetSystemTime uses, for dry runs.
+const syscallSetMechanism = "clock_settime(CLOCK_REALTIME)"
+
+// syscallSetPrecision is the resolution of the time SetSystemTime applies.
+const syscallSetPrecision = time.Nanosecond
+
+// SetSystemTime sets the system time on Linux with clock_settime, keeping the nanoseconds.
 func SetSystemTime(t time.Time) error {
-	tv := syscall.Timeval{
-		Sec:  t.Unix(),
-		Usec: int64(t.Nanosecond() / 1000),
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+
+	ts := unix.NsecToTimespec(t.UnixNano())
+	return unix.ClockSettime(unix.CLOCK_REALTIME, &ts)
+}
+
+// platformDefaults returns the built-in defaults for Linux.
+func platformDefaults() PlatformDefaults {
+	if runtime.GOOS == "android" {
+		return androidDefaults()
+	}
+	return PlatformDefaults{
+		Server:    "europe.pool.ntp.org",
+		SetMethod: SetMethodSyscall,
+		Service:   "systemd",
+		StateDir:  "/var/lib/ntpcl",
 	}
-	return syscall.Settimeofday(&tv)
 }
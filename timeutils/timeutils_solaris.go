@@ -0,0 +1,23 @@
+//go:build solaris
+// +build solaris
+
+package timeutils
+
+import "time"
+
+// syscallSetMechanism names the system call SetSystemTime uses, for dry runs.
+const syscallSetMechanism = "settimeofday"
+
+// syscallSetPrecision is the resolution of the time SetSystemTime applies.
+const syscallSetPrecision = time.Microsecond
+
+// platformDefaults returns the built-in defaults for illumos and Solaris. The
+// syscall method needs a cgo build; see settime_solaris_nocgo.go.
+func platformDefaults() PlatformDefaults {
+	return PlatformDefaults{
+		Server:    "pool.ntp.org",
+		SetMethod: solarisSetMethod,
+		Service:   "smf",
+		StateDir:  "/var/ntpcl",
+	}
+}
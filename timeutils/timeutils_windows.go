@@ -4,13 +4,25 @@
 package timeutils
 
 import (
+	"os"
+	"path/filepath"
 	"syscall"
 	"time"
 	"unsafe"
 )
 
+// syscallSetMechanism names the system call SetSystemTime uses, for dry runs.
+const syscallSetMechanism = "SetSystemTime (kernel32)"
+
+// syscallSetPrecision is the resolution of the time SetSystemTime applies.
+const syscallSetPrecision = time.Millisecond
+
 // SetSystemTime sets the system time on Windows using the Windows API.
 func SetSystemTime(t time.Time) error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+
 	utc := t.UTC()
 	systemTime := syscall.Systemtime{
 		Year:         uint16(utc.Year()),
@@ -31,3 +43,13 @@ func SetSystemTime(t time.Time) error {
 	}
 	return nil
 }
+
+// platformDefaults returns the built-in defaults for Windows.
+func platformDefaults() PlatformDefaults {
+	return PlatformDefaults{
+		Server:    "time.windows.com",
+		SetMethod: SetMethodSyscall,
+		Service:   "windows-service",
+		StateDir:  filepath.Join(os.Getenv("ProgramData"), "ntpcl"),
+	}
+}
@@ -0,0 +1,92 @@
+package timeutils
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// FetchTimeFromTLS reads the Date header of an HTTPS response from host[:port].
+// The certificate chain is verified against the fetched time instead of the local
+// clock, which may be the one that is wrong, so a session that verifies also
+// cross-checks the time against the certificate's validity window.
+func FetchTimeFromTLS(host string, httpOpts HTTPOptions) (time.Time, time.Duration, error) {
+	addr, name := tlsAddress(host)
+	rawConn, err := httpOpts.dialTLSHost(addr)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName: name,
+		// The chain is verified below, once the server time is known.
+		InsecureSkipVerify: true,
+	})
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return time.Time{}, 0, err
+	}
+	if err := conn.Handshake(); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	req, err := httpOpts.newRequest(http.MethodHead, "https://"+addr+"/")
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	req.Host = name
+	req.Close = true
+
+	dumpHTTPRequest(req)
+	// The handshake is done, so the round trip covers only the request itself.
+	start := time.Now()
+	if err := req.Write(conn); err != nil {
+		return time.Time{}, 0, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+	rtt := time.Since(start)
+	resp.Body.Close()
+	dumpHTTPResponse(resp)
+
+	dateHeader := resp.Header.Get("Date")
+	if dateHeader == "" {
+		return time.Time{}, 0, fmt.Errorf("no Date header found in response")
+	}
+	serverTime, err := http.ParseTime(dateHeader)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
+
+	if err := verifyChainAt(conn.ConnectionState().PeerCertificates, name, serverTime); err != nil {
+		return time.Time{}, 0, fmt.Errorf("certificate of %s does not verify at the server time %s: %v", name, serverTime.Format(time.RFC3339), err)
+	}
+	return serverTime, rtt, nil
+}
+
+// tlsAddress returns the dial address, defaulting to port 443, and the server name of host.
+func tlsAddress(host string) (string, string) {
+	name, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return net.JoinHostPort(host, "443"), host
+	}
+	return host, name
+}
+
+// verifyChainAt verifies a peer certificate chain for name as of t.
+func verifyChainAt(certs []*x509.Certificate, name string, t time.Time) error {
+	if len(certs) == 0 {
+		return fmt.Errorf("no certificate presented")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{DNSName: name, Intermediates: intermediates, CurrentTime: t})
+	return err
+}
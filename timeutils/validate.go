@@ -0,0 +1,68 @@
+package timeutils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/beevik/ntp"
+)
+
+// ntpEpoch is the zero NTP timestamp, which servers send as reference time when they never synchronized.
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// maxStratum is the highest stratum a usable server can report; 16 means unsynchronized.
+const maxStratum = 15
+
+// RejectedError is returned for a response that arrived but must not be used.
+type RejectedError struct {
+	Server string
+	Err    error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("rejected response from %s: %v", e.Server, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
+// MaxStratum is the highest stratum accepted from a server, set by --max-stratum.
+var MaxStratum = maxStratum
+
+// MaxRootDistance is the largest root distance accepted from a server, set by
+// --max-root-distance; 0 accepts any.
+var MaxRootDistance time.Duration
+
+// ValidateResponse rejects NTP responses that must not be used as a time source:
+// kiss-of-death and invalid strata, strata above MaxStratum, unsynchronized
+// leap indicators, zero reference timestamps, root distances above
+// MaxRootDistance and exchange timestamps that run backwards.
+func ValidateResponse(result *NTPResult) error {
+	switch {
+	case result.Stratum == 0:
+		return &KissOfDeathError{Code: result.KissCode}
+	case result.Stratum > maxStratum:
+		return fmt.Errorf("invalid stratum %d", result.Stratum)
+	case int(result.Stratum) > MaxStratum:
+		return fmt.Errorf("stratum %d is above the maximum of %d", result.Stratum, MaxStratum)
+	case result.Leap == ntp.LeapNotInSync:
+		return fmt.Errorf("server is unsynchronized (leap indicator 3)")
+	case result.ReferenceTime.Equal(ntpEpoch):
+		return fmt.Errorf("zero reference timestamp")
+	case MaxRootDistance > 0 && result.RootDistance > MaxRootDistance:
+		return fmt.Errorf("root distance %v is above the maximum of %v", result.RootDistance, MaxRootDistance)
+	}
+
+	ts := result.Timestamps
+	switch {
+	case ts.T2.Equal(ntpEpoch) || ts.T3.Equal(ntpEpoch):
+		return fmt.Errorf("zero server timestamp (T2 %s, T3 %s)", ts.T2.Format(time.RFC3339Nano), ts.T3.Format(time.RFC3339Nano))
+	case ts.T3.Before(ts.T2):
+		return fmt.Errorf("non-monotonic server timestamps: T3 %s before T2 %s", ts.T3.Format(time.RFC3339Nano), ts.T2.Format(time.RFC3339Nano))
+	case ts.T4.Before(ts.T1):
+		// The clock was stepped back while the query was out.
+		return fmt.Errorf("non-monotonic client timestamps: T4 %s before T1 %s", ts.T4.Format(time.RFC3339Nano), ts.T1.Format(time.RFC3339Nano))
+	}
+	return nil
+}
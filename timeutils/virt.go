@@ -0,0 +1,38 @@
+package timeutils
+
+import "strings"
+
+// VirtInfo describes the virtualization environment as far as it affects timekeeping.
+type VirtInfo struct {
+	// Hypervisor is empty on bare metal.
+	Hypervisor string
+	// Clocksource is the kernel clocksource in use, where the platform exposes it.
+	Clocksource string
+	// SyncServices lists active hypervisor time sync services that will fight ntpcl's corrections.
+	SyncServices []string
+}
+
+// hypervisorVendors maps DMI/BIOS vendor and product substrings to hypervisor names.
+var hypervisorVendors = []struct{ marker, name string }{
+	{"vmware", "VMware"},
+	{"virtualbox", "VirtualBox"},
+	{"innotek", "VirtualBox"},
+	{"qemu", "KVM"},
+	{"kvm", "KVM"},
+	{"amazon ec2", "KVM"},
+	{"google compute engine", "KVM"},
+	{"xen", "Xen"},
+	{"parallels", "Parallels"},
+	{"virtual machine", "Hyper-V"},
+}
+
+// classifyHypervisor names the hypervisor from the system vendor and product strings.
+func classifyHypervisor(vendor, product string) string {
+	id := strings.ToLower(vendor + " " + product)
+	for _, v := range hypervisorVendors {
+		if strings.Contains(id, v.marker) {
+			return v.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+//go:build freebsd || openbsd || netbsd
+// +build freebsd openbsd netbsd
+
+package timeutils
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// vmGuests names the hypervisors reported by the FreeBSD kern.vm_guest sysctl.
+var vmGuests = map[string]string{
+	"generic":   "unknown hypervisor",
+	"xen":       "Xen",
+	"hv":        "Hyper-V",
+	"vmware":    "VMware",
+	"kvm":       "KVM",
+	"bhyve":     "bhyve",
+	"vbox":      "VirtualBox",
+	"parallels": "Parallels",
+	"nvmm":      "NVMM",
+}
+
+// smbiosSysctls are the sysctls holding the system vendor and product on
+// OpenBSD and NetBSD, which have no kern.vm_guest.
+var smbiosSysctls = map[string][2]string{
+	"openbsd": {"hw.vendor", "hw.product"},
+	"netbsd":  {"machdep.dmi.system-vendor", "machdep.dmi.system-product"},
+}
+
+// DetectVirtualization reports the hypervisor the kernel detected, or the one
+// named by the SMBIOS strings, and the timecounter in use.
+func DetectVirtualization() VirtInfo {
+	info := VirtInfo{Clocksource: sysctlValue("kern.timecounter.hardware")}
+	if runtime.GOOS == "freebsd" {
+		if guest := sysctlValue("kern.vm_guest"); guest != "" && guest != "none" {
+			info.Hypervisor = vmGuests[guest]
+			if info.Hypervisor == "" {
+				info.Hypervisor = guest
+			}
+		}
+		return info
+	}
+	names := smbiosSysctls[runtime.GOOS]
+	info.Hypervisor = classifyHypervisor(sysctlValue(names[0]), sysctlValue(names[1]))
+	return info
+}
+
+// sysctlValue returns the value of a sysctl, or "" when it cannot be read.
+func sysctlValue(name string) string {
+	out, err := exec.Command("sysctl", "-n", name).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,27 @@
+//go:build darwin
+// +build darwin
+
+package timeutils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// DetectVirtualization reports whether macOS runs under a hypervisor. macOS
+// does not expose a selectable clocksource.
+func DetectVirtualization() VirtInfo {
+	var info VirtInfo
+	out, err := exec.Command("sysctl", "-n", "kern.hv_vmm_present").Output()
+	if err != nil || strings.TrimSpace(string(out)) != "1" {
+		return info
+	}
+
+	info.Hypervisor = "unknown hypervisor"
+	if model, err := exec.Command("sysctl", "-n", "hw.model").Output(); err == nil {
+		if name := classifyHypervisor("", string(model)); name != "" {
+			info.Hypervisor = name
+		}
+	}
+	return info
+}
@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package timeutils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const clocksourceDir = "/sys/devices/system/clocksource/clocksource0/"
+
+// hypervTimeSyncClassID is the VMBus class of the Hyper-V Time Synchronization
+// integration service, offered to the guest only while it is enabled.
+const hypervTimeSyncClassID = "{9527e630-d0ae-497b-adce-e80ab0175caf}"
+
+// DetectVirtualization inspects DMI, /sys/hypervisor and the CPU flags for a
+// hypervisor, and looks for VMware Tools and Hyper-V time synchronization.
+func DetectVirtualization() VirtInfo {
+	info := VirtInfo{Clocksource: readSysValue(clocksourceDir + "current_clocksource")}
+
+	info.Hypervisor = classifyHypervisor(readSysValue("/sys/class/dmi/id/sys_vendor"), readSysValue("/sys/class/dmi/id/product_name"))
+	if info.Hypervisor == "" {
+		if xen := readSysValue("/sys/hypervisor/type"); xen != "" {
+			info.Hypervisor = strings.ToUpper(xen[:1]) + xen[1:]
+		}
+	}
+	if info.Hypervisor == "" && strings.Contains(readSysValue(clocksourceDir+"available_clocksource"), "kvm-clock") {
+		info.Hypervisor = "KVM"
+	}
+	if info.Hypervisor == "" && cpuHasHypervisorFlag() {
+		info.Hypervisor = "unknown hypervisor"
+	}
+
+	if path, err := exec.LookPath("vmware-toolbox-cmd"); err == nil {
+		if out, err := exec.Command(path, "timesync", "status").Output(); err == nil && strings.TrimSpace(string(out)) == "Enabled" {
+			info.SyncServices = append(info.SyncServices, "VMware Tools periodic time sync")
+		}
+	}
+	if hypervTimeSyncOffered() {
+		info.SyncServices = append(info.SyncServices, "Hyper-V TimeSync integration service")
+	}
+	return info
+}
+
+// hypervTimeSyncOffered reports whether the host offers the Time
+// Synchronization service on the VMBus. hv_utils alone is loaded on every
+// Hyper-V guest, whether the service is enabled or not.
+func hypervTimeSyncOffered() bool {
+	paths, _ := filepath.Glob("/sys/bus/vmbus/devices/*/class_id")
+	for _, path := range paths {
+		if strings.EqualFold(readSysValue(path), hypervTimeSyncClassID) {
+			return true
+		}
+	}
+	return false
+}
+
+func readSysValue(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// cpuHasHypervisorFlag reports the CPUID hypervisor-present bit as exposed in /proc/cpuinfo.
+func cpuHasHypervisorFlag() bool {
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "flags") {
+			return strings.Contains(line+" ", " hypervisor ")
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+//go:build solaris
+// +build solaris
+
+package timeutils
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// DetectVirtualization names the hypervisor from the SMBIOS system information
+// record. illumos and Solaris do not expose a selectable clocksource.
+func DetectVirtualization() VirtInfo {
+	var info VirtInfo
+	out, err := exec.Command("smbios", "-t", "SMBIOS_TYPE_SYSTEM").Output()
+	if err != nil {
+		return info
+	}
+	var vendor, product string
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Manufacturer":
+			vendor = strings.TrimSpace(value)
+		case "Product":
+			product = strings.TrimSpace(value)
+		}
+	}
+	info.Hypervisor = classifyHypervisor(vendor, product)
+	return info
+}
@@ -0,0 +1,44 @@
+//go:build windows
+// +build windows
+
+package timeutils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DetectVirtualization reads the BIOS vendor strings from the registry and checks
+// whether the Hyper-V or VMware Tools time synchronization is active. Windows
+// does not expose a selectable clocksource.
+func DetectVirtualization() VirtInfo {
+	var info VirtInfo
+	const biosKey = `HKLM\HARDWARE\DESCRIPTION\System\BIOS`
+	info.Hypervisor = classifyHypervisor(registryValue(biosKey, "SystemManufacturer"), registryValue(biosKey, "SystemProductName"))
+
+	if out, err := exec.Command("sc", "query", "vmictimesync").Output(); err == nil && strings.Contains(string(out), "RUNNING") {
+		info.SyncServices = append(info.SyncServices, "Hyper-V Time Synchronization Service (vmictimesync)")
+	}
+	toolbox := filepath.Join(os.Getenv("ProgramFiles"), "VMware", "VMware Tools", "VMwareToolboxCmd.exe")
+	if out, err := exec.Command(toolbox, "timesync", "status").Output(); err == nil && strings.TrimSpace(string(out)) == "Enabled" {
+		info.SyncServices = append(info.SyncServices, "VMware Tools periodic time sync")
+	}
+	return info
+}
+
+// registryValue returns a string value from `reg query` output, or "" when it is missing.
+func registryValue(key, name string) string {
+	out, err := exec.Command("reg", "query", key, "/v", name).Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] == name && fields[1] == "REG_SZ" {
+			return strings.Join(fields[2:], " ")
+		}
+	}
+	return ""
+}
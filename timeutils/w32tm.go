@@ -0,0 +1,49 @@
+package timeutils
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// W32TimeResyncCommand is the command SetMethodW32Time runs.
+var W32TimeResyncCommand = []string{"w32tm", "/resync"}
+
+// W32TimeResync asks the Windows Time service to resynchronize from its
+// configured source, which keeps domain members on the domain hierarchy.
+func W32TimeResync() error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+	return runW32tm(W32TimeResyncCommand)
+}
+
+// ConfigureW32TimePeers points the Windows Time service at the given NTP servers
+// instead of the domain hierarchy.
+func ConfigureW32TimePeers(peers []string) error {
+	if err := checkClockWritable(); err != nil {
+		return err
+	}
+	if len(peers) == 0 {
+		return errors.New("no peers to configure")
+	}
+
+	list := make([]string, len(peers))
+	for i, peer := range peers {
+		// 0x8 sends client mode requests, as expected by most NTP servers.
+		list[i] = peer + ",0x8"
+	}
+	return runW32tm([]string{"w32tm", "/config", "/manualpeerlist:" + strings.Join(list, " "), "/syncfromflags:manual", "/update"})
+}
+
+func runW32tm(args []string) error {
+	if runtime.GOOS != "windows" {
+		return errors.New("w32tm is only available on Windows")
+	}
+	if output, err := exec.Command(args[0], args[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+)
+
+// DebugWire receives a dump of every packet sent and received by the
+// protocol clients. Nothing is dumped when it is nil.
+var DebugWire io.Writer
+
+// dumpWire hex-dumps a raw packet exchanged with peer over proto, preceded by
+// any decoded fields.
+func dumpWire(direction, proto, peer string, data []byte, fields ...string) {
+	if DebugWire == nil {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s (%d bytes)\n", direction, proto, peer, len(data))
+	for _, field := range fields {
+		fmt.Fprintf(&b, "  %s\n", field)
+	}
+	b.WriteString(hex.Dump(data))
+	b.WriteString("\n")
+	io.WriteString(DebugWire, b.String())
+}
+
+// dumpHTTPRequest writes the request line and headers of an outgoing request.
+func dumpHTTPRequest(req *http.Request) {
+	if DebugWire == nil {
+		return
+	}
+	dump, err := httputil.DumpRequestOut(req, false)
+	if err != nil {
+		fmt.Fprintf(DebugWire, "> HTTP %s: %v\n\n", req.URL.Host, err)
+		return
+	}
+	fmt.Fprintf(DebugWire, "> HTTP %s\n%s", req.URL.Host, dump)
+}
+
+// dumpHTTPResponse writes the status line and headers of a response.
+func dumpHTTPResponse(resp *http.Response) {
+	if DebugWire == nil {
+		return
+	}
+	dump, err := httputil.DumpResponse(resp, false)
+	if err != nil {
+		fmt.Fprintf(DebugWire, "< HTTP %s: %v\n\n", resp.Request.URL.Host, err)
+		return
+	}
+	fmt.Fprintf(DebugWire, "< HTTP %s\n%s", resp.Request.URL.Host, dump)
+}
+
+// ntpWireDump is an ntp.Extension that dumps the query and response packets
+// with their header fields decoded.
+type ntpWireDump struct {
+	server string
+}
+
+func (d ntpWireDump) ProcessQuery(buf *bytes.Buffer) error {
+	d.dump(">", buf.Bytes())
+	return nil
+}
+
+func (d ntpWireDump) ProcessResponse(buf []byte) error {
+	d.dump("<", buf)
+	return nil
+}
+
+func (d ntpWireDump) dump(direction string, packet []byte) {
+	if len(packet) < 48 {
+		dumpWire(direction, "NTP", d.server, packet)
+		return
+	}
+
+	timestamp := func(b []byte) string {
+		ts := binary.BigEndian.Uint64(b)
+		if ts == 0 {
+			return "0"
+		}
+		return fmt.Sprintf("%016x (%s)", ts, ntpTimestampToTime(ts).Format(time.RFC3339Nano))
+	}
+	shortFormat := func(b []byte) time.Duration {
+		v := binary.BigEndian.Uint32(b)
+		return time.Duration(v>>16)*time.Second + time.Duration(v&0xffff)*time.Second/65536
+	}
+
+	dumpWire(direction, "NTP", d.server, packet,
+		fmt.Sprintf("leap=%d version=%d mode=%d stratum=%d poll=%d precision=%d",
+			packet[0]>>6, (packet[0]>>3)&0x7, packet[0]&0x7, packet[1], int8(packet[2]), int8(packet[3])),
+		fmt.Sprintf("root_delay=%v root_dispersion=%v refid=%x", shortFormat(packet[4:8]), shortFormat(packet[8:12]), packet[12:16]),
+		"reference="+timestamp(packet[16:24]),
+		"origin="+timestamp(packet[24:32]),
+		"receive="+timestamp(packet[32:40]),
+		"transmit="+timestamp(packet[40:48]),
+	)
+}
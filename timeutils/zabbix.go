@@ -0,0 +1,130 @@
+package timeutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ZabbixSender pushes offset and RTT items to a Zabbix server or proxy using the zabbix_sender protocol.
+type ZabbixSender struct {
+	Server    string
+	Host      string
+	OffsetKey string
+	RTTKey    string
+}
+
+type zabbixItem struct {
+	Host  string `json:"host"`
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Clock int64  `json:"clock"`
+	NS    int    `json:"ns"`
+}
+
+type zabbixRequest struct {
+	Request string       `json:"request"`
+	Data    []zabbixItem `json:"data"`
+	Clock   int64        `json:"clock"`
+	NS      int          `json:"ns"`
+}
+
+type zabbixResponse struct {
+	Response string `json:"response"`
+	Info     string `json:"info"`
+}
+
+// NewZabbixSender creates a sender. The server port defaults to 10051 and the host to the local hostname.
+func NewZabbixSender(server, host, offsetKey, rttKey string) *ZabbixSender {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "10051")
+	}
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	return &ZabbixSender{Server: server, Host: host, OffsetKey: offsetKey, RTTKey: rttKey}
+}
+
+// Send pushes the offset and RTT of a measurement, in seconds.
+func (z *ZabbixSender) Send(report Report) error {
+	clock := report.LocalTime.Unix()
+	ns := report.LocalTime.Nanosecond()
+	request := zabbixRequest{
+		Request: "sender data",
+		Data: []zabbixItem{
+			{Host: z.Host, Key: z.OffsetKey, Value: strconv.FormatFloat(report.TimeDifference().Seconds(), 'f', 9, 64), Clock: clock, NS: ns},
+			{Host: z.Host, Key: z.RTTKey, Value: strconv.FormatFloat(report.RTT.Seconds(), 'f', 9, 64), Clock: clock, NS: ns},
+		},
+		Clock: clock,
+		NS:    ns,
+	}
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", z.Server, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if _, err := conn.Write(zabbixPacket(payload)); err != nil {
+		return err
+	}
+
+	reply, err := readZabbixPacket(conn)
+	if err != nil {
+		return err
+	}
+
+	var response zabbixResponse
+	if err := json.Unmarshal(reply, &response); err != nil {
+		return fmt.Errorf("invalid zabbix response: %v", err)
+	}
+	if response.Response != "success" {
+		return fmt.Errorf("zabbix rejected data: %s", response.Info)
+	}
+	if strings.Contains(response.Info, "failed: ") && !strings.Contains(response.Info, "failed: 0") {
+		return fmt.Errorf("zabbix failed to process items: %s", response.Info)
+	}
+	return nil
+}
+
+// zabbixPacket frames a payload with the ZBXD header and little-endian length.
+func zabbixPacket(payload []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("ZBXD\x01")
+	_ = binary.Write(&buf, binary.LittleEndian, uint64(len(payload)))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+func readZabbixPacket(r io.Reader) ([]byte, error) {
+	header := make([]byte, 13)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != "ZBXD" {
+		return nil, fmt.Errorf("invalid zabbix response header")
+	}
+
+	length := binary.LittleEndian.Uint32(header[5:9])
+	if length > 1<<20 {
+		return nil, fmt.Errorf("zabbix response too large: %d bytes", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}